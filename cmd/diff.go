@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/config"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+var (
+	diffCmd = &cobra.Command{
+		Use:   "diff --from <time> [--to <time>]",
+		Short: "Show how the emoji catalog changed between two points in time",
+		Long: `Compare the catalog snapshots closest to two points in time and report which
+emojis were added, removed, renamed or given a new image. Times are dates
+(2026-09-01), dates with a time (2026-09-01T15:04) in local time, or RFC 3339.`,
+		Args: cobra.NoArgs,
+		RunE: runDiff,
+	}
+
+	diffFrom   string
+	diffTo     string
+	diffOutput string
+)
+
+// layouts accepted for --from and --to, tried in order
+var diffTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04", time.DateTime, time.DateOnly}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffFrom, "from", "", "start of the period")
+	diffCmd.Flags().StringVar(&diffTo, "to", "", "end of the period (default: now)")
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "text", "output format: text, json or markdown")
+	diffCmd.MarkFlagRequired("from")
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	from, err := parseDiffTime(diffFrom)
+	if err != nil {
+		return fmt.Errorf("invalid --from: %w", err)
+	}
+	to := time.Now()
+	if diffTo != "" {
+		if to, err = parseDiffTime(diffTo); err != nil {
+			return fmt.Errorf("invalid --to: %w", err)
+		}
+	}
+	if !to.After(from) {
+		return errors.New("--to must be after --from")
+	}
+
+	st, err := openStoreReadOnly(config.New())
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	fromSnap, toSnap, err := diffSnapshots(st, from, to)
+	if err != nil {
+		return err
+	}
+	diff := store.DiffSnapshots(fromSnap, toSnap)
+
+	switch diffOutput {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	case "text":
+		return writeDiffText(os.Stdout, diff)
+	case "markdown":
+		return writeDiffMarkdown(os.Stdout, diff)
+	default:
+		return fmt.Errorf("unsupported output format %q", diffOutput)
+	}
+}
+
+func parseDiffTime(value string) (time.Time, error) {
+	for _, layout := range diffTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", value)
+}
+
+// diffSnapshots picks the snapshots to compare: the latest one taken at or
+// before each point in time. If the period starts before the first snapshot,
+// the first snapshot within it is used instead.
+func diffSnapshots(st *store.Store, from, to time.Time) (*store.Snapshot, *store.Snapshot, error) {
+	times, err := st.SnapshotTimes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	if len(times) == 0 {
+		return nil, nil, errors.New("no catalog snapshots have been taken yet, see SNAPSHOT_INTERVAL")
+	}
+
+	toSnap, err := st.SnapshotAt(to)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, fmt.Errorf("no snapshot taken before %s, the oldest is from %s", to.Format(time.DateTime), times[0].Local().Format(time.DateTime))
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	fromSnap, err := st.SnapshotAt(from)
+	if errors.Is(err, store.ErrNotFound) {
+		log.Warn().Time("oldest", times[0]).Msg("period starts before the first snapshot, comparing from the oldest one")
+		fromSnap, err = st.GetSnapshot(times[0])
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return fromSnap, toSnap, nil
+}
+
+// emojiLabel names an emoji along with the emoji it aliases, if any
+func emojiLabel(e store.SnapshotEmoji) string {
+	if e.AliasOf != "" {
+		return fmt.Sprintf(":%s: (alias of :%s:)", e.Name, e.AliasOf)
+	}
+	return fmt.Sprintf(":%s:", e.Name)
+}
+
+func writeDiffText(w io.Writer, diff *store.CatalogDiff) error {
+	fmt.Fprintf(w, "Emoji catalog changes from %s to %s\n", diff.From.Local().Format(time.DateTime), diff.To.Local().Format(time.DateTime))
+
+	fmt.Fprintf(w, "\nAdded (%d):\n", len(diff.Added))
+	for _, e := range diff.Added {
+		fmt.Fprintf(w, "  + %s\n", emojiLabel(e))
+	}
+	fmt.Fprintf(w, "\nRemoved (%d):\n", len(diff.Removed))
+	for _, e := range diff.Removed {
+		fmt.Fprintf(w, "  - %s\n", emojiLabel(e))
+	}
+	fmt.Fprintf(w, "\nRenamed (%d):\n", len(diff.Renamed))
+	for _, r := range diff.Renamed {
+		fmt.Fprintf(w, "  ~ :%s: -> :%s:\n", r.From.Name, r.To.Name)
+	}
+	fmt.Fprintf(w, "\nRe-imaged (%d):\n", len(diff.Reimaged))
+	for _, r := range diff.Reimaged {
+		fmt.Fprintf(w, "  * %s\n", emojiLabel(r.After))
+	}
+	return nil
+}
+
+func writeDiffMarkdown(w io.Writer, diff *store.CatalogDiff) error {
+	fmt.Fprintf(w, "## Emoji catalog changes from %s to %s\n", diff.From.Local().Format(time.DateOnly), diff.To.Local().Format(time.DateOnly))
+
+	fmt.Fprintf(w, "\n### Added (%d)\n\n", len(diff.Added))
+	for _, e := range diff.Added {
+		fmt.Fprintf(w, "- %s\n", markdownEmoji(e))
+	}
+	fmt.Fprintf(w, "\n### Removed (%d)\n\n", len(diff.Removed))
+	for _, e := range diff.Removed {
+		fmt.Fprintf(w, "- %s\n", markdownEmoji(e))
+	}
+	fmt.Fprintf(w, "\n### Renamed (%d)\n\n", len(diff.Renamed))
+	for _, r := range diff.Renamed {
+		fmt.Fprintf(w, "- `:%s:` → `:%s:`\n", r.From.Name, r.To.Name)
+	}
+	fmt.Fprintf(w, "\n### Re-imaged (%d)\n\n", len(diff.Reimaged))
+	for _, r := range diff.Reimaged {
+		fmt.Fprintf(w, "- `:%s:` (%s → %s)\n", r.After.Name, markdownImage(r.Before, "before"), markdownImage(r.After, "after"))
+	}
+	return nil
+}
+
+// markdownEmoji formats an emoji as a markdown list item, linking to its image
+func markdownEmoji(e store.SnapshotEmoji) string {
+	return fmt.Sprintf("`:%s:` (%s)", e.Name, markdownImage(e, "image"))
+}
+
+// markdownImage links to an emoji's image, or names the emoji it aliases
+func markdownImage(e store.SnapshotEmoji, label string) string {
+	if e.AliasOf != "" {
+		return fmt.Sprintf("alias of `:%s:`", e.AliasOf)
+	}
+	return fmt.Sprintf("[%s](%s)", label, e.URL)
+}
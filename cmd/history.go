@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/config"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+var (
+	historyCmd = &cobra.Command{
+		Use:   "history <name>",
+		Short: "Show the timeline of an emoji",
+		Long: `Show when an emoji was added, aliased, renamed, replaced and removed, and every
+announcement posted about it with its generated text, model and permalink.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runHistory,
+	}
+
+	historyOutput string
+)
+
+func init() {
+	historyCmd.Flags().StringVarP(&historyOutput, "output", "o", "table", "output format: table or json")
+
+	rootCmd.AddCommand(historyCmd)
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	st, err := openStoreReadOnly(config.New())
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	name := strings.Trim(args[0], ":")
+	events, err := st.History(name)
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+
+	switch historyOutput {
+	case "json":
+		if events == nil {
+			events = []store.HistoryEvent{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(events)
+
+	case "table":
+		if len(events) == 0 {
+			log.Info().Str("emoji", name).Msg("no history recorded for emoji")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tEVENT\tDETAIL\tMODEL\tLINK")
+		for _, e := range events {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.At.Local().Format(time.DateTime), historyEventName(e), historyDetail(e), e.Model, e.Permalink)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unsupported output format %q", historyOutput)
+	}
+}
+
+// historyEventName names an event for the table, including what was announced
+func historyEventName(e store.HistoryEvent) string {
+	if e.Kind != "" {
+		return fmt.Sprintf("%s (%s)", e.Type, e.Kind)
+	}
+	return string(e.Type)
+}
+
+// historyDetail summarizes an event on a single line
+func historyDetail(e store.HistoryEvent) string {
+	var detail string
+	switch e.Type {
+	case store.HistoryAlias:
+		detail = fmt.Sprintf("alias of :%s:", e.AliasOf)
+	case store.HistoryRenamed:
+		detail = fmt.Sprintf("renamed from :%s:", e.PreviousName)
+	case store.HistoryAnnounced:
+		detail = e.Text
+	case store.HistoryEdited:
+		detail = fmt.Sprintf("%s (by %s)", e.Text, e.User)
+	case store.HistoryDeleted:
+		detail = "by " + e.User
+	case store.HistoryUndelivered:
+		detail = e.Error
+	default:
+		detail = e.URL
+	}
+	return strings.Join(strings.Fields(detail), " ")
+}
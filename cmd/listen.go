@@ -5,9 +5,10 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
+	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/particledecay/slackmoji-notifier/pkg/config"
 	"github.com/particledecay/slackmoji-notifier/pkg/llm"
 	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
 )
 
 var listenCmd = &cobra.Command{
@@ -24,7 +26,14 @@ var listenCmd = &cobra.Command{
 	Run:   runListen,
 }
 
+var (
+	listenMode string
+	listenPoll bool
+)
+
 func init() {
+	listenCmd.Flags().StringVar(&listenMode, "mode", "", "how to receive emoji events: socket, http or poll (default from SLACK_MODE, or socket)")
+	listenCmd.Flags().BoolVar(&listenPoll, "poll", false, "poll the emoji catalog instead of using Socket Mode (same as --mode=poll)")
 	rootCmd.AddCommand(listenCmd)
 }
 
@@ -38,28 +47,32 @@ func createLLMClient(cfg *config.Config) (llm.LLMClient, error) {
 		if err != nil {
 			return nil, err
 		}
-		log.Debug().Msg("OpenAI client initialized")
+		client = llm.WithTimeout(client, cfg.OpenAI.Timeout)
+		log.Debug().Dur("timeout", cfg.OpenAI.Timeout).Msg("OpenAI client initialized")
 
 	case "ollama":
 		client, err = llm.NewOllamaClient(cfg.Ollama.Model, cfg.Ollama.BaseURL, cfg.SystemPrompt)
 		if err != nil {
 			return nil, err
 		}
-		log.Debug().Msg("Ollama client initialized")
+		client = llm.WithTimeout(client, cfg.Ollama.Timeout)
+		log.Debug().Dur("timeout", cfg.Ollama.Timeout).Msg("Ollama client initialized")
 
 	case "anthropic":
 		client, err = llm.NewAnthropicClient(cfg.Anthropic.APIKey, cfg.Anthropic.Model, cfg.SystemPrompt, cfg.Anthropic.MaxTokens)
 		if err != nil {
 			return nil, err
 		}
-		log.Debug().Msg("Anthropic client initialized")
+		client = llm.WithTimeout(client, cfg.Anthropic.Timeout)
+		log.Debug().Dur("timeout", cfg.Anthropic.Timeout).Msg("Anthropic client initialized")
 
 	case "googleai":
 		client, err = llm.NewGoogleAIClient(cfg.GoogleAI.APIKey, cfg.GoogleAI.Model, cfg.SystemPrompt, cfg.GoogleAI.MaxTokens)
 		if err != nil {
 			return nil, err
 		}
-		log.Debug().Msg("GoogleAI client initialized")
+		client = llm.WithTimeout(client, cfg.GoogleAI.Timeout)
+		log.Debug().Dur("timeout", cfg.GoogleAI.Timeout).Msg("GoogleAI client initialized")
 
 	default:
 		return nil, fmt.Errorf("unsupported LLM provider: %s", cfg.LLMProvider)
@@ -68,10 +81,163 @@ func createLLMClient(cfg *config.Config) (llm.LLMClient, error) {
 	return client, nil
 }
 
+// personas hands out one LLM client per system prompt, so destinations and
+// rules with the same prompt share a client
+type personas struct {
+	cfg     *config.Config
+	clients map[string]llm.LLMClient
+}
+
+func newPersonas(cfg *config.Config, llmClient llm.LLMClient) *personas {
+	return &personas{cfg: cfg, clients: map[string]llm.LLMClient{cfg.SystemPrompt: llmClient}}
+}
+
+// client returns the LLM client for a system prompt, creating it on first use
+func (p *personas) client(prompt string) (llm.LLMClient, error) {
+	if client, ok := p.clients[prompt]; ok {
+		return client, nil
+	}
+	cfg := *p.cfg
+	cfg.SystemPrompt = prompt
+	client, err := createLLMClient(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[prompt] = client
+	return client, nil
+}
+
+// createDestinations parses the configured destinations, giving each the LLM
+// client for its system prompt and its templates. Anything left unset uses
+// the defaults.
+func createDestinations(cfg *config.Config, p *personas, templates *notifier.Templates) ([]*notifier.Destination, error) {
+	destinations, err := notifier.ParseDestinations(cfg.Notifier.Destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dest := range destinations {
+		dest.SystemPrompt = strings.TrimSpace(dest.SystemPrompt)
+		if dest.SystemPrompt == "" {
+			dest.SystemPrompt = cfg.SystemPrompt
+		}
+		if dest.LLM, err = p.client(dest.SystemPrompt); err != nil {
+			return nil, fmt.Errorf("failed to create LLM client for destination %q: %w", dest.Name, err)
+		}
+
+		dest.Templates = templates
+		if dest.TemplateDir != "" {
+			if dest.Templates, err = notifier.LoadTemplates(dest.TemplateDir); err != nil {
+				return nil, fmt.Errorf("invalid message templates for destination %q: %w", dest.Name, err)
+			}
+		}
+		log.Debug().Str("destination", dest.Name).Str("channel", dest.Channel).Msg("configured destination")
+	}
+	return destinations, nil
+}
+
+// createRules parses and compiles the configured rules, giving the ones that
+// switch the prompt an LLM client for it
+func createRules(cfg *config.Config, p *personas, destinations []*notifier.Destination) ([]*notifier.Rule, error) {
+	rules, err := notifier.ParseRules(cfg.Notifier.Rules, destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if rule.Prompt == "" {
+			continue
+		}
+		if rule.LLM, err = p.client(rule.Prompt); err != nil {
+			return nil, fmt.Errorf("failed to create LLM client for rule %q: %w", rule.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+// newNotifier builds a notifier with every option the configuration sets, so
+// commands replaying work behave exactly like listen
+func newNotifier(cfg *config.Config, llmClient llm.LLMClient, st *store.Store) (*notifier.Notifier, error) {
+	queuePolicy, err := notifier.ParseBackpressurePolicy(cfg.Notifier.QueuePolicy)
+	if err != nil {
+		return nil, err
+	}
+	aliasMode, err := notifier.ParseAliasMode(cfg.Notifier.AliasMode)
+	if err != nil {
+		return nil, err
+	}
+	renameNotice, err := notifier.ParseNoticeMode(cfg.Notifier.RenameNotice)
+	if err != nil {
+		return nil, err
+	}
+	removalNotice, err := notifier.ParseNoticeMode(cfg.Notifier.RemovalNotice)
+	if err != nil {
+		return nil, err
+	}
+
+	quietHours, err := notifier.ParseQuietHours(cfg.Notifier.QuietHours)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := notifier.LoadTemplates(cfg.Notifier.TemplateDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message templates: %w", err)
+	}
+
+	llmPersonas := newPersonas(cfg, llmClient)
+	destinations, err := createDestinations(cfg, llmPersonas, templates)
+	if err != nil {
+		return nil, err
+	}
+	if err := notifier.CheckQuietHoursChannels(quietHours, destinations, cfg.Slack.RemovalChannel); err != nil {
+		return nil, err
+	}
+	rules, err := createRules(cfg, llmPersonas, destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	buttons := cfg.Notifier.Buttons
+	if buttons && cfg.Slack.Mode != config.ModeSocket {
+		log.Warn().Str("mode", cfg.Slack.Mode).Msg("announcement buttons need Socket Mode, leaving them out")
+		buttons = false
+	}
+	if buttons && len(cfg.Notifier.Admins) == 0 && len(cfg.Notifier.Editors) == 0 {
+		log.Warn().Msg("announcement buttons need NOTIFIER_ADMINS or NOTIFIER_EDITORS, leaving them out")
+		buttons = false
+	}
+
+	return notifier.New(llmClient, st,
+		notifier.WithLogOnly(cfg.Slack.LogOnly),
+		notifier.WithDedupeWindow(cfg.Slack.DedupeWindow),
+		notifier.WithWorkers(cfg.Notifier.Workers),
+		notifier.WithQueue(cfg.Notifier.QueueSize, queuePolicy),
+		notifier.WithAliasMode(aliasMode),
+		notifier.WithRenameNotice(renameNotice),
+		notifier.WithRemovalNotice(removalNotice, cfg.Slack.RemovalChannel),
+		notifier.WithReplaceWindow(cfg.Notifier.ReplaceWindow),
+		notifier.WithBurstDigest(cfg.Notifier.BurstThreshold, cfg.Notifier.BurstWindow),
+		notifier.WithPacks(cfg.Notifier.PackWindow, cfg.Notifier.PackMinSize),
+		notifier.WithDailyThread(cfg.Notifier.DailyThread),
+		notifier.WithQuietHours(quietHours),
+		notifier.WithTemplates(templates),
+		notifier.WithDestinations(destinations),
+		notifier.WithRules(rules),
+		notifier.WithButtons(buttons, cfg.Notifier.Admins, cfg.Notifier.Editors),
+	), nil
+}
+
 func runListen(cmd *cobra.Command, args []string) {
 	log.Debug().Msg("starting listen command")
 
 	cfg := config.New()
+	if listenMode != "" {
+		cfg.Slack.Mode = listenMode
+	}
+	if listenPoll {
+		cfg.Slack.Mode = config.ModePoll
+	}
 	if err := cfg.Validate(); err != nil {
 		log.Fatal().Err(err).Msg("invalid configuration")
 	}
@@ -82,20 +248,52 @@ func runListen(cmd *cobra.Command, args []string) {
 		log.Fatal().Err(err).Msg("failed to create LLM client")
 	}
 
-	n := notifier.New(llmClient, cfg.Slack.LogOnly)
+	st, err := openStore(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to open state store")
+	}
+	defer st.Close()
+	log.Debug().Str("driver", cfg.State.Driver).Str("path", cfg.State.Path).Msg("state store opened")
+
+	var roundupSchedule cron.Schedule
+	if cfg.Roundup.Schedule != "" {
+		if roundupSchedule, err = notifier.ParseRoundupSchedule(cfg.Roundup.Schedule, cfg.Roundup.Timezone); err != nil {
+			log.Fatal().Err(err).Msg("invalid configuration")
+		}
+	}
+
+	n, err := newNotifier(cfg, llmClient, st)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid configuration")
+	}
 	log.Debug().Msg("notifier created")
 
-	debugEventHandler := func(event interface{}) {
+	debugEventHandler := func(ctx context.Context, event interface{}) {
 		log.Debug().Interface("event", event).Msg("received Slack event")
-		n.HandleEvent(event)
+		n.HandleEvent(ctx, event)
 	}
 
-	log.Debug().Str("channel", cfg.Slack.Channel).Msg("initializing Slack client")
-	slackClient, err := slack.NewClient(
-		slack.WithAPIToken(cfg.Slack.BotToken, cfg.Slack.AppToken),
+	log.Debug().Str("channel", cfg.Slack.Channel).Str("mode", cfg.Slack.Mode).Msg("initializing Slack client")
+	transportOptions := []slack.ClientOption{slack.WithAPIToken(cfg.Slack.BotToken, cfg.Slack.AppToken)}
+	switch cfg.Slack.Mode {
+	case config.ModeHTTP:
+		transportOptions = []slack.ClientOption{
+			slack.WithBotToken(cfg.Slack.BotToken),
+			slack.WithHTTPEvents(cfg.Slack.HTTPAddr, cfg.Slack.HTTPPath, cfg.Slack.SigningSecret),
+		}
+	case config.ModePoll:
+		transportOptions = []slack.ClientOption{slack.WithBotToken(cfg.Slack.BotToken)}
+	}
+	slackClient, err := slack.NewClient(append(transportOptions,
 		slack.WithChannel(cfg.Slack.Channel),
 		slack.WithEventHandler(debugEventHandler),
-	)
+		slack.WithInteractionHandler(n.HandleInteraction),
+		slack.WithQueueSize(cfg.Slack.EventQueueSize),
+		slack.WithReconnectBackoff(cfg.Slack.ReconnectBackoff, cfg.Slack.ReconnectMax),
+		slack.WithStaleTimeout(cfg.Slack.StaleTimeout),
+		slack.WithAPITimeout(cfg.Slack.APITimeout),
+		slack.WithAdminToken(cfg.Slack.AdminToken),
+	)...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to create Slack client")
 	}
@@ -104,6 +302,9 @@ func runListen(cmd *cobra.Command, args []string) {
 	n.SetSlackClient(slackClient)
 	log.Debug().Msg("Slack client set in notifier")
 
+	// additions held for a digest or pack when the last run stopped
+	n.ResumeHeld()
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	go func() {
@@ -114,27 +315,60 @@ func runListen(cmd *cobra.Command, args []string) {
 		cancel()
 	}()
 
-	log.Info().Msg("starting event listener")
+	if cfg.HealthAddr != "" {
+		startHealthServer(ctx, cfg.HealthAddr, func() (bool, string) {
+			if cfg.Slack.Mode == config.ModePoll {
+				return true, "polling"
+			}
+			state := slackClient.ConnectionState()
+			return state != slack.StateStopped, string(state)
+		})
+	}
 
-	if err := slackClient.ListenForEvents(); err != nil {
-		log.Error().Err(err).Msg("event listener stopped")
-		cancel()
+	if cfg.Slack.Mode == config.ModePoll {
+		log.Info().Dur("interval", cfg.Slack.PollInterval).Msg("starting emoji catalog polling")
+		n.StartReconciler(ctx, cfg.Slack.PollInterval)
+	} else {
+		log.Info().Str("mode", cfg.Slack.Mode).Msg("starting event listener")
+
+		if err := slackClient.ListenForEvents(ctx); err != nil {
+			log.Error().Err(err).Msg("event listener stopped")
+			cancel()
+		}
+
+		if cfg.Slack.ReconcileInterval > 0 {
+			log.Debug().Dur("interval", cfg.Slack.ReconcileInterval).Msg("starting emoji catalog reconciler")
+			n.StartReconciler(ctx, cfg.Slack.ReconcileInterval)
+		}
+	}
+
+	if cfg.Snapshot.Interval > 0 {
+		log.Debug().Dur("interval", cfg.Snapshot.Interval).Msg("starting emoji catalog snapshotter")
+		n.StartSnapshotter(ctx, cfg.Snapshot.Interval, cfg.Snapshot.Retention)
+	}
+
+	// runs even without quiet hours so announcements held before a config change still go out
+	n.StartQuietHours(ctx)
+
+	if cfg.Roundup.Schedule != "" {
+		log.Debug().Str("schedule", cfg.Roundup.Schedule).Str("timezone", cfg.Roundup.Timezone).Msg("starting emoji roundup scheduler")
+		n.StartRoundup(ctx, roundupSchedule)
 	}
 
 	<-ctx.Done()
-	log.Info().Msg("shutting down")
+	log.Info().Dur("timeout", cfg.ShutdownTimeout).Msg("shutting down")
 
-	// Give ongoing operations some time to complete
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer shutdownCancel()
 
-	slackClient.Stop()
+	// stop accepting events first so everything already accepted reaches the
+	// notifier, sharing the shutdown deadline with the notifier below
+	slackClient.Stop(shutdownCtx)
 	log.Debug().Msg("Slack client stopped")
 
-	select {
-	case <-shutdownCtx.Done():
-		log.Warn().Msg("shutdown timed out")
-	default:
-		log.Info().Msg("shutdown completed successfully")
+	if err := n.Shutdown(shutdownCtx); err != nil {
+		log.Warn().Err(err).Msg("shutdown did not complete cleanly")
+		return
 	}
+	log.Info().Msg("shutdown completed successfully")
 }
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/config"
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+var (
+	outboxCmd = &cobra.Command{
+		Use:   "outbox",
+		Short: "Manage announcements that could not be delivered",
+		Long:  `Inspect, replay and discard announcements that failed after exhausting their retries.`,
+	}
+
+	outboxListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List undelivered announcements",
+		Args:  cobra.NoArgs,
+		RunE:  runOutboxList,
+	}
+
+	outboxRetryCmd = &cobra.Command{
+		Use:   "retry [id...]",
+		Short: "Retry undelivered announcements",
+		Long:  `Make one more delivery attempt for each given outbox entry. Delivered entries are removed from the outbox.`,
+		RunE:  runOutboxRetry,
+	}
+
+	outboxDropCmd = &cobra.Command{
+		Use:   "drop [id...]",
+		Short: "Discard undelivered announcements",
+		RunE:  runOutboxDrop,
+	}
+
+	outboxOutput   string
+	outboxRetryAll bool
+	outboxDropAll  bool
+)
+
+func init() {
+	outboxListCmd.Flags().StringVarP(&outboxOutput, "output", "o", "table", "output format: table or json")
+	outboxRetryCmd.Flags().BoolVar(&outboxRetryAll, "all", false, "retry every entry in the outbox")
+	outboxDropCmd.Flags().BoolVar(&outboxDropAll, "all", false, "drop every entry in the outbox")
+
+	outboxCmd.AddCommand(outboxListCmd)
+	outboxCmd.AddCommand(outboxRetryCmd)
+	outboxCmd.AddCommand(outboxDropCmd)
+	rootCmd.AddCommand(outboxCmd)
+}
+
+func runOutboxList(cmd *cobra.Command, args []string) error {
+	st, err := openStoreReadOnly(config.New())
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	entries, err := st.ListOutbox()
+	if err != nil {
+		return fmt.Errorf("failed to list outbox: %w", err)
+	}
+
+	switch outboxOutput {
+	case "json":
+		if entries == nil {
+			entries = []*store.OutboxEntry{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTAGE\tCLASS\tATTEMPTS\tUPDATED\tERROR")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", e.ID, e.Stage, e.Class, e.Attempts, e.UpdatedAt.Local().Format(time.DateTime), e.Error)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unsupported output format %q", outboxOutput)
+	}
+}
+
+// outboxIDs resolves the entries a command applies to from its arguments or --all
+func outboxIDs(st *store.Store, args []string, all bool) ([]string, error) {
+	if all == (len(args) > 0) {
+		return nil, errors.New("pass either one or more outbox IDs or --all")
+	}
+	if !all {
+		return args, nil
+	}
+
+	entries, err := st.ListOutbox()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox: %w", err)
+	}
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return ids, nil
+}
+
+func runOutboxRetry(cmd *cobra.Command, args []string) error {
+	cfg := config.New()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	st, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	ids, err := outboxIDs(st, args, outboxRetryAll)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		log.Info().Msg("outbox is empty")
+		return nil
+	}
+
+	llmClient, err := createLLMClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create LLM client: %w", err)
+	}
+	slackClient, err := slack.NewClient(
+		slack.WithBotToken(cfg.Slack.BotToken),
+		slack.WithChannel(cfg.Slack.Channel),
+		slack.WithAPITimeout(cfg.Slack.APITimeout),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create Slack client: %w", err)
+	}
+
+	// entries remember their destination and rule, which must still be configured
+	n, err := newNotifier(cfg, llmClient, st)
+	if err != nil {
+		return err
+	}
+	n.SetSlackClient(slackClient)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := n.Shutdown(shutdownCtx); err != nil {
+			log.Warn().Err(err).Msg("notifier did not shut down cleanly")
+		}
+	}()
+
+	failed := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := n.RetryOutbox(ctx, id); err != nil {
+			log.Error().Err(err).Str("id", id).Msg("retry failed")
+			failed++
+			continue
+		}
+		log.Info().Str("id", id).Msg("announcement delivered")
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d announcements could not be delivered", failed, len(ids))
+	}
+	return nil
+}
+
+func runOutboxDrop(cmd *cobra.Command, args []string) error {
+	st, err := openStore(config.New())
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	ids, err := outboxIDs(st, args, outboxDropAll)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if _, err := st.GetOutbox(id); err != nil {
+			return fmt.Errorf("outbox entry %q: %w", id, err)
+		}
+		if err := st.DeleteOutbox(id); err != nil {
+			return fmt.Errorf("failed to drop %q: %w", id, err)
+		}
+		log.Info().Str("id", id).Msg("dropped outbox entry")
+	}
+	return nil
+}
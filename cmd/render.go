@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/particledecay/slackmoji-notifier/internal/notifier"
+	"github.com/particledecay/slackmoji-notifier/pkg/config"
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+)
+
+var (
+	renderCmd = &cobra.Command{
+		Use:   "render <add|alias|rename|remove|digest|replace|return|pack|struck>",
+		Short: "Preview an announcement template with sample data",
+		Long: `Render the template for an announcement with sample data, using the custom
+templates in NOTIFIER_TEMPLATE_DIR (or --template-dir) where there are any.
+The json output is the Block Kit payload, which can be pasted into Slack's
+Block Kit Builder.`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: notifier.TemplateKinds,
+		RunE:      runRender,
+	}
+
+	renderTemplateDir string
+	renderName        string
+	renderSentence    string
+	renderUploader    string
+	renderOutput      string
+)
+
+func init() {
+	renderCmd.Flags().StringVar(&renderTemplateDir, "template-dir", "", "directory of custom templates (default: NOTIFIER_TEMPLATE_DIR)")
+	renderCmd.Flags().StringVar(&renderName, "name", "", "emoji name to use instead of the sample")
+	renderCmd.Flags().StringVar(&renderSentence, "sentence", "", "generated sentence to use instead of the sample")
+	renderCmd.Flags().StringVar(&renderUploader, "uploader", "", "uploader's Slack user ID to use instead of the sample")
+	renderCmd.Flags().StringVarP(&renderOutput, "output", "o", "text", "output format: text or json")
+
+	rootCmd.AddCommand(renderCmd)
+}
+
+func runRender(cmd *cobra.Command, args []string) error {
+	kind := args[0]
+	if !slices.Contains(notifier.TemplateKinds, kind) {
+		return fmt.Errorf("unknown announcement %q, expected one of: %s", kind, strings.Join(notifier.TemplateKinds, ", "))
+	}
+
+	dir := renderTemplateDir
+	if dir == "" {
+		dir = config.New().Notifier.TemplateDir
+	}
+	templates, err := notifier.LoadTemplates(dir)
+	if err != nil {
+		return err
+	}
+
+	data := notifier.SampleTemplateData(kind)
+	if renderName != "" {
+		data.Name = strings.Trim(renderName, ":")
+		data.Names[0] = data.Name
+	}
+	if renderSentence != "" {
+		data.Sentence = renderSentence
+	}
+	if renderUploader != "" {
+		data.Uploader = renderUploader
+	}
+
+	content, err := templates.Preview(kind, data)
+	if err != nil {
+		return err
+	}
+
+	switch renderOutput {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(map[string]interface{}{"text": content.Fallback(), "blocks": content.Blocks()})
+	case "text":
+		return writeRenderText(os.Stdout, content)
+	default:
+		return fmt.Errorf("unsupported output format %q", renderOutput)
+	}
+}
+
+func writeRenderText(w io.Writer, content slack.MessageContent) error {
+	fmt.Fprintf(w, "Notification: %s\n", content.Fallback())
+	if content.Header != "" {
+		fmt.Fprintf(w, "\nHeader:\n  %s\n", content.Header)
+	}
+	if content.Body != "" {
+		fmt.Fprintf(w, "\nBody:\n  %s\n", strings.ReplaceAll(content.Body, "\n", "\n  "))
+	}
+	for _, image := range content.Images {
+		fmt.Fprintf(w, "\nImage: %s\n  alt: %s\n", image.URL, image.AltText)
+	}
+	if len(content.Context) > 0 {
+		fmt.Fprintf(w, "\nContext:\n  %s\n", strings.Join(content.Context, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/particledecay/slackmoji-notifier/internal/notifier"
+	"github.com/particledecay/slackmoji-notifier/pkg/config"
+)
+
+var (
+	rulesCmd = &cobra.Command{
+		Use:   "rules",
+		Short: "Work with the rules that filter and route emoji changes",
+	}
+
+	rulesTestCmd = &cobra.Command{
+		Use:   "test <emoji>",
+		Short: "Show which rule an emoji change would match",
+		Long: `Evaluate the rules in NOTIFIER_RULES (or --rules) against an emoji change
+described by the flags, and show what would happen to it. Nothing is posted.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runRulesTest,
+	}
+
+	rulesTestRules        string
+	rulesTestSubtype      string
+	rulesTestAliasOf      string
+	rulesTestUploader     string
+	rulesTestPreviousName string
+	rulesTestSize         int64
+	rulesTestTime         string
+)
+
+func init() {
+	rulesTestCmd.Flags().StringVar(&rulesTestRules, "rules", "", "rules as inline JSON or a file path (default: NOTIFIER_RULES)")
+	rulesTestCmd.Flags().StringVar(&rulesTestSubtype, "subtype", "add", "kind of change: add, remove or rename")
+	rulesTestCmd.Flags().StringVar(&rulesTestAliasOf, "alias-of", "", "emoji the new emoji is an alias of")
+	rulesTestCmd.Flags().StringVar(&rulesTestUploader, "uploader", "", "uploader's Slack user ID")
+	rulesTestCmd.Flags().StringVar(&rulesTestPreviousName, "previous-name", "", "name before a rename")
+	rulesTestCmd.Flags().Int64Var(&rulesTestSize, "size", 0, "image size in bytes")
+	rulesTestCmd.Flags().StringVar(&rulesTestTime, "time", "", "time of the change in RFC 3339 format (default: now)")
+
+	rulesCmd.AddCommand(rulesTestCmd)
+	rootCmd.AddCommand(rulesCmd)
+}
+
+func runRulesTest(cmd *cobra.Command, args []string) error {
+	switch rulesTestSubtype {
+	case "add", "remove", "rename":
+	default:
+		return fmt.Errorf("unsupported subtype %q, expected add, remove or rename", rulesTestSubtype)
+	}
+
+	ev := notifier.RuleEvent{
+		Name:         strings.Trim(args[0], ":"),
+		Subtype:      rulesTestSubtype,
+		AliasOf:      strings.Trim(rulesTestAliasOf, ":"),
+		Uploader:     rulesTestUploader,
+		PreviousName: strings.Trim(rulesTestPreviousName, ":"),
+		Size:         rulesTestSize,
+		Time:         time.Now(),
+	}
+	if rulesTestTime != "" {
+		t, err := time.Parse(time.RFC3339, rulesTestTime)
+		if err != nil {
+			return fmt.Errorf("invalid time %q: %w", rulesTestTime, err)
+		}
+		ev.Time = t
+	}
+
+	cfg := config.New()
+	if rulesTestRules != "" {
+		cfg.Notifier.Rules = rulesTestRules
+	}
+	destinations, err := notifier.ParseDestinations(cfg.Notifier.Destinations)
+	if err != nil {
+		return err
+	}
+	rules, err := notifier.ParseRules(cfg.Notifier.Rules, destinations)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("no rules configured, set NOTIFIER_RULES or pass --rules")
+	}
+
+	return writeRulesTest(os.Stdout, rules, ev)
+}
+
+func writeRulesTest(out io.Writer, rules []*notifier.Rule, ev notifier.RuleEvent) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tRESULT\tACTION")
+	for _, rule := range rules {
+		result := "no match"
+		matched, err := rule.Matches(ev)
+		switch {
+		case err != nil:
+			result = "error: " + err.Error()
+		case matched:
+			result = "match"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", rule.Name, result, ruleAction(rule))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	rule := notifier.MatchRule(rules, ev)
+	if rule == nil {
+		fmt.Fprintf(out, "No rule matches, so :%s: is announced as usual.\n", ev.Name)
+		return nil
+	}
+	fmt.Fprintf(out, "Rule %q applies: %s\n", rule.Name, ruleAction(rule))
+	return nil
+}
+
+// ruleAction describes what a rule does to the changes it matches
+func ruleAction(rule *notifier.Rule) string {
+	if rule.Drop {
+		return "drop"
+	}
+	var actions []string
+	if len(rule.Route) > 0 {
+		actions = append(actions, "route to "+strings.Join(rule.Route, ", "))
+	}
+	if rule.Prompt != "" {
+		actions = append(actions, fmt.Sprintf("use prompt %q", rule.Prompt))
+	}
+	return strings.Join(actions, ", ")
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/config"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+var (
+	stateCmd = &cobra.Command{
+		Use:   "state",
+		Short: "Manage persisted notifier state",
+		Long:  `Inspect, back up and restore the state the notifier keeps between restarts.`,
+	}
+
+	stateExportCmd = &cobra.Command{
+		Use:   "export [file]",
+		Short: "Export state to a JSON file",
+		Long:  `Export the full notifier state as JSON to the given file, or to stdout if no file (or "-") is given.`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  runStateExport,
+	}
+
+	stateImportCmd = &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import state from a JSON file",
+		Long:  `Import notifier state previously written by "state export". Use "-" to read from stdin.`,
+		Args:  cobra.ExactArgs(1),
+		RunE:  runStateImport,
+	}
+
+	stateImportReplace bool
+)
+
+func init() {
+	stateImportCmd.Flags().BoolVar(&stateImportReplace, "replace", false, "remove all existing state before importing")
+
+	stateCmd.AddCommand(stateExportCmd)
+	stateCmd.AddCommand(stateImportCmd)
+	rootCmd.AddCommand(stateCmd)
+}
+
+func openStore(cfg *config.Config) (*store.Store, error) {
+	return store.Open(cfg.State.Driver, cfg.State.Path)
+}
+
+// openStoreReadOnly opens the state for commands that only read it, which
+// works while listen is running
+func openStoreReadOnly(cfg *config.Config) (*store.Store, error) {
+	return store.OpenReadOnly(cfg.State.Driver, cfg.State.Path)
+}
+
+func runStateExport(cmd *cobra.Command, args []string) error {
+	st, err := openStoreReadOnly(config.New())
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	var w io.Writer = os.Stdout
+	if len(args) == 1 && args[0] != "-" {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := st.Export(w); err != nil {
+		return fmt.Errorf("failed to export state: %w", err)
+	}
+	log.Debug().Msg("state exported")
+	return nil
+}
+
+func runStateImport(cmd *cobra.Command, args []string) error {
+	st, err := openStore(config.New())
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	count, err := st.Import(r, stateImportReplace)
+	if err != nil {
+		return fmt.Errorf("failed to import state: %w", err)
+	}
+	log.Info().Int("records", count).Bool("replace", stateImportReplace).Msg("state imported")
+	return nil
+}
@@ -0,0 +1,162 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+const (
+	aliasPrefix = "alias:"
+
+	// maxAliasHops bounds how far alias chains are followed when resolving images
+	maxAliasHops = 5
+)
+
+// AliasMode decides how emoji aliases are announced
+type AliasMode string
+
+const (
+	// AliasAnnounce posts an "X is now an alias of Y" message
+	AliasAnnounce AliasMode = "announce"
+	// AliasFold records the alias on its target without posting anything
+	AliasFold AliasMode = "fold"
+	// AliasIgnore only remembers the alias as known
+	AliasIgnore AliasMode = "ignore"
+)
+
+const defaultAliasMode = AliasAnnounce
+
+// ParseAliasMode validates an alias mode name
+func ParseAliasMode(value string) (AliasMode, error) {
+	switch mode := AliasMode(value); mode {
+	case AliasAnnounce, AliasFold, AliasIgnore:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported alias mode %q", value)
+	}
+}
+
+// WithAliasMode sets how emoji aliases are announced
+func WithAliasMode(mode AliasMode) Option {
+	return func(n *Notifier) {
+		if mode != "" {
+			n.aliasMode = mode
+		}
+	}
+}
+
+// parseAlias returns the target of an emoji catalog value like "alias:target"
+func parseAlias(value string) (string, bool) {
+	target, ok := strings.CutPrefix(value, aliasPrefix)
+	if !ok || target == "" {
+		return "", false
+	}
+	return target, true
+}
+
+// handleNewAlias applies the alias mode to a newly added alias and reports
+// whether it still needs to be announced
+func (n *Notifier) handleNewAlias(name, target string) bool {
+	logger := log.With().Str("emoji", name).Str("alias_of", target).Str("mode", string(n.aliasMode)).Logger()
+
+	switch n.aliasMode {
+	case AliasIgnore:
+		logger.Info().Msg("ignoring new alias")
+		return false
+	case AliasFold:
+		n.linkAlias(name, target)
+		logger.Info().Msg("folded new alias into its target")
+		return false
+	default:
+		logger.Info().Msg("handling new alias")
+		return true
+	}
+}
+
+// linkAlias records an alias on its target emoji, if the target is a known custom emoji
+func (n *Notifier) linkAlias(name, target string) {
+	n.eventsMutex.Lock()
+	defer n.eventsMutex.Unlock()
+
+	emoji, err := n.store.GetEmoji(target)
+	// aliases of aliases are folded into the emoji the chain ends at
+	for hops := 0; err == nil && emoji.AliasOf != "" && hops < maxAliasHops; hops++ {
+		emoji, err = n.store.GetEmoji(emoji.AliasOf)
+	}
+	if err != nil || emoji.AliasOf != "" {
+		log.Debug().Err(err).Str("emoji", name).Str("alias_of", target).Msg("alias target is not a known custom emoji")
+		return
+	}
+	if slices.Contains(emoji.Aliases, name) {
+		return
+	}
+	emoji.Aliases = append(emoji.Aliases, name)
+	if err := n.store.PutEmoji(emoji); err != nil {
+		log.Error().Err(err).Str("emoji", emoji.Name).Msg("failed to save emoji state")
+	}
+}
+
+// unlinkAlias removes a deleted alias from its target emoji
+func (n *Notifier) unlinkAlias(name, target string) {
+	emoji, err := n.store.GetEmoji(target)
+	for hops := 0; err == nil && emoji.AliasOf != "" && hops < maxAliasHops; hops++ {
+		emoji, err = n.store.GetEmoji(emoji.AliasOf)
+	}
+	if err != nil {
+		return
+	}
+	aliases := slices.DeleteFunc(slices.Clone(emoji.Aliases), func(a string) bool { return a == name })
+	if len(aliases) == len(emoji.Aliases) {
+		return
+	}
+	emoji.Aliases = aliases
+	if err := n.store.PutEmoji(emoji); err != nil {
+		log.Error().Err(err).Str("emoji", emoji.Name).Msg("failed to save emoji state")
+	}
+}
+
+// resolveAliasImage follows an alias chain to the image of the emoji it ends
+// at. It returns "" when the chain ends at a standard emoji, which has no image.
+func (n *Notifier) resolveAliasImage(ctx context.Context, target string) string {
+	var catalog map[string]string
+
+	for range maxAliasHops {
+		value := ""
+		if emoji, err := n.store.GetEmoji(target); err == nil && emoji.Active {
+			value = emoji.URL
+		} else {
+			if catalog == nil {
+				var err error
+				if catalog, err = n.slackClient.ListEmojis(ctx); err != nil {
+					log.Warn().Err(err).Str("emoji", target).Msg("failed to resolve alias target")
+					return ""
+				}
+			}
+			value = catalog[target]
+		}
+
+		next, ok := parseAlias(value)
+		if !ok {
+			return value
+		}
+		target = next
+	}
+
+	log.Warn().Str("emoji", target).Msg("alias chain too long, not resolving image")
+	return ""
+}
+
+// buildAliasAnnouncement builds the Slack message announcing a new alias,
+// showing its target's image if it could be resolved
+func buildAliasAnnouncement(t *Templates, emoji *store.Emoji, imageURL string) slack.MessageContent {
+	content := t.render(TemplateAlias, emojiTemplateData(emoji, emoji.AddedAt))
+	content.Images = emojiImage(emoji.AliasOf, imageURL, fmt.Sprintf("%s → %s", emoji.Name, emoji.AliasOf))
+	return content
+}
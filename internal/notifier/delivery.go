@@ -0,0 +1,455 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// Kinds of announcement, also recorded on outbox entries
+const (
+	announceAdd     = "add"
+	announceRename  = "rename"
+	announceRemove  = "remove"
+	announceReplace = "replace"
+	announceReturn  = "return"
+	announceDigest  = "digest"
+	announcePack    = "pack"
+)
+
+// delivery is an announcement on its way to Slack. The generated sentence is
+// kept across attempts so a failed send doesn't pay for another completion.
+// Digests and packs announce every emoji in batch and are keyed by the first one.
+// A delivery without a destination is fanned out to every destination first.
+type delivery struct {
+	kind         string
+	emoji        *store.Emoji
+	batch        []*store.Emoji
+	pack         string
+	previousName string
+	previousURL  string
+	sentence     string
+	model        string
+	attempts     int
+	ref          slack.MessageRef
+	dest         *Destination
+	shared       map[string]generated
+	rule         *Rule
+}
+
+// id is the outbox key of the delivery. New emoji announcements are keyed by
+// the emoji name alone so operators can refer to them directly. Named
+// destinations add an @name suffix.
+func (d *delivery) id() string {
+	id := d.kind + ":" + d.emoji.Name
+	if d.kind == "" || d.kind == announceAdd {
+		id = d.emoji.Name
+	}
+	if d.dest != nil && d.dest.Name != "" {
+		id += "@" + d.dest.Name
+	}
+	return id
+}
+
+// emojis returns every emoji the delivery announces
+func (d *delivery) emojis() []*store.Emoji {
+	if len(d.batch) > 0 {
+		return d.batch
+	}
+	return []*store.Emoji{d.emoji}
+}
+
+// attempt runs whichever stages of the delivery haven't succeeded yet
+func (n *Notifier) attempt(ctx context.Context, d *delivery) error {
+	d.attempts++
+
+	messageContent, err := n.buildMessage(ctx, d)
+	if err != nil {
+		return err
+	}
+	log.Debug().Interface("messageContent", messageContent).Msg("sending message to Slack")
+
+	var ref slack.MessageRef
+	if n.threaded(d, messageContent) {
+		ref, err = n.postInDailyThread(ctx, d, messageContent)
+	} else {
+		ref, err = n.slackClient.SendMessage(ctx, messageContent)
+	}
+	if err != nil {
+		return classify(StageSend, err)
+	}
+	d.ref = ref
+	log.Debug().Msg("message sent successfully to Slack")
+	return nil
+}
+
+// buildMessage generates the sentence for a delivery if it needs one and
+// builds its message for the channel it is posted to
+func (n *Notifier) buildMessage(ctx context.Context, d *delivery) (slack.MessageContent, error) {
+	content, err := n.compose(ctx, d)
+	if err != nil {
+		return slack.MessageContent{}, err
+	}
+	content.Channel = n.channelOf(d)
+	if n.hasButtons(d) {
+		content.Buttons = n.announcementButtons()
+	}
+	return content, nil
+}
+
+// compose builds a delivery's message from its destination's templates
+func (n *Notifier) compose(ctx context.Context, d *delivery) (slack.MessageContent, error) {
+	t := n.templatesFor(d)
+	switch {
+	case d.kind == announceRename:
+		if n.renameNotice == NoticeLLM && d.sentence == "" {
+			prompt := fmt.Sprintf("emoji renamed from: %s to: %s", d.previousName, d.emoji.Name)
+			if err := n.generate(ctx, d, prompt); err != nil {
+				return slack.MessageContent{}, err
+			}
+		}
+		return buildRenameNotice(t, d.previousName, d.emoji, d.sentence), nil
+
+	case d.kind == announceRemove:
+		if n.removalNotice == NoticeLLM && d.sentence == "" {
+			if err := n.generate(ctx, d, "emoji removed: "+d.emoji.Name); err != nil {
+				return slack.MessageContent{}, err
+			}
+		}
+		return buildRemovalNotice(t, d.emoji, d.sentence), nil
+
+	case d.kind == announceReplace:
+		if d.sentence == "" {
+			if err := n.generate(ctx, d, "emoji image updated: "+d.emoji.Name); err != nil {
+				return slack.MessageContent{}, err
+			}
+		}
+		return buildUpdateAnnouncement(t, d.emoji, d.previousURL, d.sentence), nil
+
+	case d.kind == announceReturn:
+		if d.sentence == "" {
+			if err := n.generate(ctx, d, "emoji name: "+d.emoji.Name); err != nil {
+				return slack.MessageContent{}, err
+			}
+		}
+		earlier := n.earlierAnnouncement(ctx, d)
+		return buildWelcomeBackAnnouncement(t, d.emoji, d.sentence, earlier), nil
+
+	case d.kind == announceDigest:
+		if d.sentence == "" {
+			if err := n.generate(ctx, d, "emoji names: "+strings.Join(emojiNames(d.batch), ", ")); err != nil {
+				return slack.MessageContent{}, err
+			}
+		}
+		return buildDigestAnnouncement(t, d.batch, d.sentence), nil
+
+	case d.kind == announcePack:
+		names := emojiNames(d.batch)
+		if d.sentence == "" {
+			prompt := fmt.Sprintf("emoji pack: %s with emojis: %s", d.pack, strings.Join(names, ", "))
+			if err := n.generate(ctx, d, prompt); err != nil {
+				return slack.MessageContent{}, err
+			}
+		}
+		return buildPackAnnouncement(t, d.pack, d.batch, d.sentence), nil
+
+	case d.emoji.AliasOf != "":
+		imageURL := n.resolveAliasImage(ctx, d.emoji.AliasOf)
+		return buildAliasAnnouncement(t, d.emoji, imageURL), nil
+
+	default:
+		if d.sentence == "" {
+			if err := n.generate(ctx, d, "emoji name: "+d.emoji.Name); err != nil {
+				return slack.MessageContent{}, err
+			}
+		}
+		return buildAnnouncement(t, d.emoji, d.sentence), nil
+	}
+}
+
+// generate asks the LLM for the delivery's sentence, reusing one already
+// written for another destination with the same system prompt
+func (n *Notifier) generate(ctx context.Context, d *delivery, prompt string) error {
+	var key string
+	if d.dest != nil {
+		key = d.dest.SystemPrompt + "\x00" + prompt
+	}
+	if g, ok := d.shared[key]; ok {
+		log.Debug().Str("kind", d.kind).Str("destination", d.dest.Name).Msg("reusing sentence generated for another destination")
+		d.sentence, d.model = g.sentence, g.model
+		return nil
+	}
+
+	client := n.llmFor(d)
+	sentence, err := client.GenerateCompletion(ctx, prompt, false)
+	if err != nil {
+		return classify(StageGenerate, err)
+	}
+	log.Debug().Str("kind", d.kind).Str("sentence", sentence).Msg("generated sentence")
+	d.sentence = sentence
+	d.model = client.Model()
+	if d.shared != nil {
+		d.shared[key] = generated{sentence: d.sentence, model: d.model}
+	}
+	return nil
+}
+
+// deliver sends an announcement to every destination that accepts it, with
+// retries, moving it to the outbox if it still fails. Announcements for a
+// channel in its quiet hours are held instead. It reports whether the
+// announcement was delivered anywhere.
+func (n *Notifier) deliver(ctx context.Context, d *delivery) bool {
+	if d.dest == nil {
+		var delivered bool
+		for _, c := range n.fanOut(d) {
+			if n.deliver(ctx, c) {
+				delivered = true
+			}
+		}
+		return delivered
+	}
+
+	if n.holdForQuietHours(ctx, d) {
+		return false
+	}
+	return n.send(ctx, d)
+}
+
+// send delivers an announcement right away
+func (n *Notifier) send(ctx context.Context, d *delivery) bool {
+	if err := n.deliverWithRetry(ctx, d); err != nil {
+		n.deadLetter(d, err)
+		return false
+	}
+	n.delivered(ctx, d)
+	return true
+}
+
+// delivered records a successful delivery and clears any outbox entry for it.
+// Messages showing the emoji's image become its announcement.
+func (n *Notifier) delivered(ctx context.Context, d *delivery) {
+	switch d.kind {
+	case "", announceAdd, announceReplace, announceReturn:
+		n.recordAnnouncement(d)
+		n.saveEmoji(d.emoji)
+	case announceDigest, announcePack:
+		// these aren't any one emoji's announcement, so they are never updated in place
+		for _, emoji := range d.batch {
+			emoji.AnnouncedAt = time.Now()
+			n.saveEmoji(emoji)
+		}
+	}
+
+	if err := n.store.DeleteOutbox(d.id()); err != nil {
+		log.Error().Err(err).Str("emoji", d.emoji.Name).Msg("failed to clear outbox entry")
+	}
+	n.rememberPosted(d)
+	n.recordAnnounced(ctx, d)
+}
+
+// deliverWithRetry attempts a delivery until it succeeds or the retry policy
+// for the latest failure's class is exhausted
+func (n *Notifier) deliverWithRetry(ctx context.Context, d *delivery) error {
+	for {
+		err := n.attempt(ctx, d)
+		if err == nil {
+			return nil
+		}
+
+		var derr *DeliveryError
+		errors.As(err, &derr)
+		policy := defaultRetryPolicies[derr.Class]
+
+		logger := log.With().
+			Err(derr.Err).
+			Str("emoji", d.emoji.Name).
+			Str("stage", derr.Stage).
+			Str("class", string(derr.Class)).
+			Int("attempt", d.attempts).
+			Logger()
+
+		if d.attempts >= policy.MaxAttempts {
+			logger.Error().Msg("giving up on announcement")
+			return err
+		}
+
+		wait := policy.delay(d.attempts, derr.RetryAfter)
+		logger.Warn().Dur("retry_in", wait).Msg("announcement failed, retrying")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return classify(derr.Stage, ctx.Err())
+		case <-n.draining:
+			// don't hold up shutdown waiting to retry
+			timer.Stop()
+			logger.Warn().Msg("shutting down, not retrying announcement")
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// deadLetter stores an exhausted delivery in the outbox for an operator to replay
+func (n *Notifier) deadLetter(d *delivery, err error) {
+	var derr *DeliveryError
+	if !errors.As(err, &derr) {
+		derr = classify(StageSend, err)
+	}
+
+	now := time.Now()
+	entry, getErr := n.store.GetOutbox(d.id())
+	if getErr != nil {
+		entry = &store.OutboxEntry{ID: d.id(), CreatedAt: now}
+	}
+	d.describe(entry)
+	entry.Stage = derr.Stage
+	entry.Class = string(derr.Class)
+	entry.Error = derr.Err.Error()
+	entry.Attempts += d.attempts
+	entry.UpdatedAt = now
+
+	if err := n.store.PutOutbox(entry); err != nil {
+		log.Error().Err(err).Str("emoji", d.emoji.Name).Msg("failed to save undelivered announcement to outbox")
+		return
+	}
+	n.deadLettered.Add(1)
+	for _, emoji := range d.emojis() {
+		n.record(store.HistoryEvent{
+			Type:  store.HistoryUndelivered,
+			Emoji: emoji.Name,
+			Kind:  d.kind,
+			Text:  d.sentence,
+			Model: d.model,
+			Error: entry.Error,
+		})
+	}
+	log.Warn().Str("emoji", d.emoji.Name).Str("class", entry.Class).Msg("announcement moved to outbox")
+}
+
+// RetryOutbox makes a single delivery attempt for an outbox entry. The entry
+// is removed on success and updated with the new failure otherwise.
+func (n *Notifier) RetryOutbox(ctx context.Context, id string) error {
+	entry, err := n.store.GetOutbox(id)
+	if err != nil {
+		return err
+	}
+
+	d, err := n.restore(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := n.attempt(ctx, d); err != nil {
+		n.deadLetter(d, err)
+		return err
+	}
+
+	n.delivered(ctx, d)
+	return nil
+}
+
+// describe records what's needed to rebuild the delivery on a stored entry
+func (d *delivery) describe(entry *store.OutboxEntry) {
+	entry.Kind = d.kind
+	entry.Emoji = d.emoji.Name
+	if len(d.batch) > 0 {
+		entry.Emojis = emojiNames(d.batch)
+	}
+	entry.Pack = d.pack
+	entry.PreviousName = d.previousName
+	entry.PreviousURL = d.previousURL
+	entry.URL = d.emoji.URL
+	entry.Sentence = d.sentence
+	entry.Model = d.model
+	if d.dest != nil {
+		entry.Destination = d.dest.Name
+	}
+	if d.rule != nil {
+		entry.Rule = d.rule.Name
+	}
+}
+
+// restore rebuilds the delivery a stored entry describes
+func (n *Notifier) restore(entry *store.OutboxEntry) (*delivery, error) {
+	emoji, err := n.store.GetEmoji(entry.Emoji)
+	if errors.Is(err, store.ErrNotFound) {
+		emoji = &store.Emoji{Name: entry.Emoji, URL: entry.URL, Active: true, AddedAt: entry.CreatedAt}
+	} else if err != nil {
+		return nil, err
+	}
+	if emoji.URL == "" {
+		emoji.URL = entry.URL
+	}
+
+	d := &delivery{kind: entry.Kind, emoji: emoji, previousName: entry.PreviousName, previousURL: entry.PreviousURL, sentence: entry.Sentence, model: entry.Model, pack: entry.Pack}
+	d.rule = n.rule(entry.Rule)
+	d.dest = d.rule.persona(n.destination(entry.Destination))
+	for _, name := range entry.Emojis {
+		member, err := n.store.GetEmoji(name)
+		if errors.Is(err, store.ErrNotFound) {
+			member = &store.Emoji{Name: name, Active: true}
+		} else if err != nil {
+			return nil, err
+		}
+		d.batch = append(d.batch, member)
+	}
+	return d, nil
+}
+
+// buildAnnouncement builds the Slack message announcing a new emoji
+func buildAnnouncement(t *Templates, emoji *store.Emoji, sentence string) slack.MessageContent {
+	data := emojiTemplateData(emoji, emoji.AddedAt)
+	data.Sentence = sentence
+	content := t.render(TemplateAdd, data)
+	content.Images = emojiImage(emoji.Name, emoji.URL, emoji.Name)
+	return content
+}
+
+// emojiTemplateData describes a single emoji to a message template
+func emojiTemplateData(emoji *store.Emoji, at time.Time) TemplateData {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	data := TemplateData{
+		Name:     emoji.Name,
+		Uploader: emoji.AddedBy,
+		AliasOf:  emoji.AliasOf,
+		Names:    []string{emoji.Name},
+		Count:    1,
+		Time:     at,
+	}
+	if emoji.AliasOf == "" {
+		data.ImageURL = emoji.URL
+	}
+	return data
+}
+
+// emojiImage shows an emoji's full-size image, or nothing if its URL is unknown
+func emojiImage(name, url, title string) []slack.Image {
+	if url == "" {
+		return nil
+	}
+	return []slack.Image{{URL: fullSizeImageURL(url), AltText: fmt.Sprintf("the %s emoji", name), Title: title}}
+}
+
+// emojiContext lists the code to type an emoji with
+func emojiContext(name string) []string {
+	return []string{fmt.Sprintf("`:%s:`", name)}
+}
+
+// fullSizeImageURL asks Slack for the full-size version of an emoji image
+func fullSizeImageURL(url string) string {
+	if !strings.Contains(url, "?") {
+		return url + "?size=512"
+	}
+	return url + "&size=512"
+}
@@ -0,0 +1,277 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/llm"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// DestinationKinds lists the announcements a destination can filter on.
+// Aliases are additions of an alias rather than a new image.
+var DestinationKinds = []string{announceAdd, "alias", announceRename, announceRemove, announceReplace, announceReturn, announceDigest, announcePack, roundupKind}
+
+// Destination is a channel announcements are posted to, with its own persona
+// and templates. Destinations with the same SystemPrompt share generated
+// sentences, so an announcement only pays for one completion per persona.
+type Destination struct {
+	// Name tells destinations apart in the state; it defaults to the channel
+	Name string `json:"name"`
+	// Channel is where announcements are posted, empty for the default channel
+	Channel      string `json:"channel"`
+	SystemPrompt string `json:"system_prompt"`
+	TemplateDir  string `json:"template_dir"`
+	// Include and Exclude are path.Match patterns of emoji names, and Kinds
+	// the announcements to post. Empty lists allow everything.
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+	Kinds   []string `json:"kinds"`
+
+	// LLM and Templates default to the notifier's own
+	LLM       llm.LLMClient `json:"-"`
+	Templates *Templates    `json:"-"`
+}
+
+// ParseDestinations parses a JSON list of destinations, either inline or in
+// the file the value names. An empty value configures none.
+func ParseDestinations(value string) ([]*Destination, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	source := []byte(value)
+	if !strings.HasPrefix(value, "[") {
+		var err error
+		if source, err = os.ReadFile(value); err != nil {
+			return nil, fmt.Errorf("failed to read destinations: %w", err)
+		}
+	}
+
+	var destinations []*Destination
+	if err := json.Unmarshal(source, &destinations); err != nil {
+		return nil, fmt.Errorf("invalid destinations: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i, dest := range destinations {
+		if dest == nil {
+			return nil, fmt.Errorf("destination %d is empty", i+1)
+		}
+		if dest.Name == "" {
+			dest.Name = strings.TrimPrefix(dest.Channel, "#")
+		}
+		if dest.Name == "" {
+			return nil, fmt.Errorf("destination %d needs a name or a channel", i+1)
+		}
+		if strings.ContainsAny(dest.Name, "@:") {
+			return nil, fmt.Errorf("invalid destination name %q: it can't contain @ or :", dest.Name)
+		}
+		if seen[dest.Name] {
+			return nil, fmt.Errorf("destination %q is configured more than once", dest.Name)
+		}
+		seen[dest.Name] = true
+
+		for _, pattern := range append(slices.Clone(dest.Include), dest.Exclude...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q for destination %q: %w", pattern, dest.Name, err)
+			}
+		}
+		for _, kind := range dest.Kinds {
+			if !slices.Contains(DestinationKinds, kind) {
+				return nil, fmt.Errorf("unknown announcement %q for destination %q, expected one of: %s", kind, dest.Name, strings.Join(DestinationKinds, ", "))
+			}
+		}
+	}
+	return destinations, nil
+}
+
+// WithDestinations posts every announcement to each destination that accepts
+// it instead of only the default channel. The first destination keeps the
+// announcement that is updated when an emoji is replaced or removed; the
+// others are tracked as copies.
+func WithDestinations(destinations []*Destination) Option {
+	return func(n *Notifier) {
+		if len(destinations) > 0 {
+			n.destinations = destinations
+		}
+	}
+}
+
+// accepts reports whether a destination posts an announcement of the given
+// kind about the named emoji
+func (dest *Destination) accepts(kind, name string) bool {
+	if len(dest.Kinds) > 0 && !slices.Contains(dest.Kinds, kind) {
+		return false
+	}
+	if len(dest.Include) > 0 && !slices.ContainsFunc(dest.Include, globMatches(name)) {
+		return false
+	}
+	return !slices.ContainsFunc(dest.Exclude, globMatches(name))
+}
+
+// globMatches returns a test for whether a pattern matches name
+func globMatches(name string) func(string) bool {
+	return func(pattern string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+}
+
+// generated is a sentence shared between the copies of an announcement
+type generated struct {
+	sentence string
+	model    string
+}
+
+// fanOut copies a delivery for every destination that accepts it, leaving
+// batches with only the members a destination accepts. A rule's route picks
+// the destinations instead of their filters. Removal notices for a dedicated
+// removal channel are only posted once.
+func (n *Notifier) fanOut(d *delivery) []*delivery {
+	shared := make(map[string]generated)
+	var copies []*delivery
+	for _, dest := range n.destinations {
+		c := *d
+		c.dest, c.shared = d.rule.persona(dest), shared
+
+		if d.rule != nil && len(d.rule.Route) > 0 {
+			if !slices.Contains(d.rule.Route, dest.Name) {
+				continue
+			}
+		} else if len(d.batch) > 0 {
+			c.batch = nil
+			for _, emoji := range d.batch {
+				if dest.accepts(d.filterKind(), emoji.Name) {
+					c.batch = append(c.batch, emoji)
+				}
+			}
+			if len(c.batch) == 0 {
+				continue
+			}
+			c.emoji = c.batch[0]
+		} else if !dest.accepts(d.filterKind(), d.emoji.Name) {
+			continue
+		}
+
+		copies = append(copies, &c)
+		if d.kind == announceRemove && n.removalChannel != "" {
+			break
+		}
+	}
+
+	if len(copies) == 0 {
+		log.Debug().Str("emoji", d.emoji.Name).Str("kind", d.kind).Msg("no destination accepts announcement")
+	}
+	return copies
+}
+
+// filterKind is the kind destinations filter the delivery by
+func (d *delivery) filterKind() string {
+	switch {
+	case (d.kind == "" || d.kind == announceAdd) && d.emoji.AliasOf != "":
+		return "alias"
+	case d.kind == "":
+		return announceAdd
+	}
+	return d.kind
+}
+
+// destination returns the named destination, or the first one if it is no
+// longer configured
+func (n *Notifier) destination(name string) *Destination {
+	for _, dest := range n.destinations {
+		if dest.Name == name {
+			return dest
+		}
+	}
+	log.Warn().Str("destination", name).Str("using", n.destinations[0].Name).Msg("unknown destination, using the first one")
+	return n.destinations[0]
+}
+
+// primary reports whether a delivery goes to the first destination
+func (n *Notifier) primary(d *delivery) bool {
+	return d.dest == nil || d.dest.Name == n.destinations[0].Name
+}
+
+// llmFor returns the LLM client that writes a delivery's sentence
+func (n *Notifier) llmFor(d *delivery) llm.LLMClient {
+	if d.dest != nil && d.dest.LLM != nil {
+		return d.dest.LLM
+	}
+	return n.llmClient
+}
+
+// templatesFor returns the templates a delivery's message is rendered from
+func (n *Notifier) templatesFor(d *delivery) *Templates {
+	if d.dest != nil && d.dest.Templates != nil {
+		return d.dest.Templates
+	}
+	return n.templates
+}
+
+// announcementTemplates returns the templates of the destination an earlier
+// announcement was posted to
+func (n *Notifier) announcementTemplates(a store.AnnouncementCopy) *Templates {
+	dest := n.destinations[0]
+	if a.Destination != "" {
+		dest = n.destination(a.Destination)
+	}
+	return n.templatesFor(&delivery{dest: dest})
+}
+
+// recordAnnouncement stores a delivered announcement as the emoji's own for
+// the first destination, or as a copy for any other
+func (n *Notifier) recordAnnouncement(d *delivery) {
+	now := time.Now()
+	if n.primary(d) {
+		d.emoji.AnnouncedAt = now
+		d.emoji.Sentence = d.sentence
+		d.emoji.Announcement = &store.MessageRef{Channel: d.ref.Channel, Timestamp: d.ref.Timestamp}
+		return
+	}
+
+	c := store.AnnouncementCopy{
+		MessageRef:  store.MessageRef{Channel: d.ref.Channel, Timestamp: d.ref.Timestamp},
+		Destination: d.dest.Name,
+		Sentence:    d.sentence,
+		AnnouncedAt: now,
+	}
+	d.emoji.Copies = slices.DeleteFunc(d.emoji.Copies, func(old store.AnnouncementCopy) bool {
+		return old.Destination == c.Destination
+	})
+	d.emoji.Copies = append(d.emoji.Copies, c)
+}
+
+// previousAnnouncement returns an emoji's announcement in a delivery's
+// destination, if it has one
+func (n *Notifier) previousAnnouncement(d *delivery) (store.AnnouncementCopy, bool) {
+	if !n.primary(d) {
+		i := slices.IndexFunc(d.emoji.Copies, func(c store.AnnouncementCopy) bool { return c.Destination == d.dest.Name })
+		if i < 0 {
+			return store.AnnouncementCopy{}, false
+		}
+		return d.emoji.Copies[i], true
+	}
+	if d.emoji.Announcement == nil {
+		return store.AnnouncementCopy{}, false
+	}
+	return store.AnnouncementCopy{MessageRef: *d.emoji.Announcement, Sentence: d.emoji.Sentence, AnnouncedAt: d.emoji.AnnouncedAt}, true
+}
+
+// announcements returns an emoji's announcement in every destination
+func announcements(emoji *store.Emoji) []store.AnnouncementCopy {
+	var all []store.AnnouncementCopy
+	if emoji.Announcement != nil {
+		all = append(all, store.AnnouncementCopy{MessageRef: *emoji.Announcement, Sentence: emoji.Sentence, AnnouncedAt: emoji.AnnouncedAt})
+	}
+	return append(all, emoji.Copies...)
+}
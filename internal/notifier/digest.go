@@ -0,0 +1,295 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+const (
+	defaultBurstThreshold = 5
+	defaultBurstWindow    = 1 * time.Minute
+
+	// maxDigestSize caps how many emojis a single digest lists
+	maxDigestSize = 50
+	// digestGridWidth is how many emojis share a line of a text grid
+	digestGridWidth = 5
+)
+
+// WithBurstDigest collects additions into a single digest once more than
+// threshold of them arrive within window. Every addition is held for up to
+// window in case it starts a burst, and is announced on its own if the burst
+// doesn't pass the threshold. Once it does, the digest is posted when no
+// addition has arrived for window. A zero threshold announces every addition
+// on its own right away. Additions that matched different rules go in separate
+// digests.
+func WithBurstDigest(threshold int, window time.Duration) Option {
+	return func(n *Notifier) {
+		if threshold >= 0 {
+			n.burst.threshold = threshold
+		}
+		if window > 0 {
+			n.burst.window = window
+		}
+	}
+}
+
+// burstBuffer holds the additions of a possible burst
+type burstBuffer struct {
+	threshold int
+	window    time.Duration
+
+	mu      sync.Mutex
+	pending []heldEmoji
+	timer   *time.Timer
+}
+
+// heldEmoji is an addition held for a digest with the rule it matched, if any
+type heldEmoji struct {
+	emoji *store.Emoji
+	rule  *Rule
+}
+
+// bufferBurst holds an addition until it is known whether it is part of a
+// burst and reports whether it was held. The first addition starts a window
+// that isn't extended until the burst passes the threshold, so a single
+// upload waits at most one window.
+func (n *Notifier) bufferBurst(emoji *store.Emoji, rule *Rule) bool {
+	b := &n.burst
+	if b.threshold <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// packs too small to announce are handed on here, and may be flushed
+	// after drainHeld has taken the last held additions
+	if n.shuttingDown() {
+		return false
+	}
+
+	n.markHeld(emoji)
+	b.pending = append(b.pending, heldEmoji{emoji: emoji, rule: rule})
+	log.Debug().Str("emoji", emoji.Name).Int("pending", len(b.pending)).Msg("holding emoji in case of a burst")
+
+	if len(b.pending) >= maxDigestSize {
+		n.startFlush(b.takePending(), b.threshold)
+		return true
+	}
+	if b.timer != nil && len(b.pending) <= b.threshold {
+		return true
+	}
+	if len(b.pending) == b.threshold+1 {
+		log.Info().Int("pending", len(b.pending)).Msg("burst of additions, holding them for a digest")
+	}
+
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.timer = time.AfterFunc(b.window, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		n.startFlush(b.takePending(), b.threshold)
+	})
+	return true
+}
+
+// takePending empties the digest buffer. The caller must hold b.mu.
+func (b *burstBuffer) takePending() []heldEmoji {
+	batch := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// startFlush announces held additions in the background. Up to threshold of
+// them are announced one by one, and more go in one digest per rule they
+// matched. The caller must hold n.burst.mu so drainHeld can't miss a flush
+// that is just starting.
+func (n *Notifier) startFlush(held []heldEmoji, threshold int) {
+	if len(held) <= threshold {
+		for _, h := range held {
+			n.inBackground(func() { n.flushDigest([]*store.Emoji{h.emoji}, h.rule) })
+		}
+		return
+	}
+
+	var rules []*Rule
+	batches := make(map[*Rule][]*store.Emoji)
+	for _, h := range held {
+		if _, ok := batches[h.rule]; !ok {
+			rules = append(rules, h.rule)
+		}
+		batches[h.rule] = append(batches[h.rule], h.emoji)
+	}
+	for _, rule := range rules {
+		batch := batches[rule]
+		n.inBackground(func() { n.flushDigest(batch, rule) })
+	}
+}
+
+// inBackground runs an announcement of held emojis on its own goroutine,
+// tracked so Shutdown can wait for it
+func (n *Notifier) inBackground(fn func()) {
+	n.flushes.Add(1)
+	go func() {
+		defer n.flushes.Done()
+		fn()
+	}()
+}
+
+// flushDigest announces a batch of held additions that matched the same rule,
+// if any, as one digest
+func (n *Notifier) flushDigest(batch []*store.Emoji, rule *Rule) {
+	defer n.unmarkHeld(batch)
+	if len(batch) == 1 {
+		// not part of a burst, or the only one of it that matched this rule
+		n.deliver(n.ctx, &delivery{kind: announceAdd, emoji: batch[0], rule: rule})
+		return
+	}
+	log.Info().Int("emojis", len(batch)).Msg("announcing digest of new emojis")
+	n.deliver(n.ctx, &delivery{kind: announceDigest, emoji: batch[0], batch: batch, rule: rule})
+}
+
+// drainHeld announces every held addition, and waits for those and any
+// digests or packs already in flight
+func (n *Notifier) drainHeld() {
+	n.drainPacks()
+
+	n.burst.mu.Lock()
+	n.startFlush(n.burst.takePending(), n.burst.threshold)
+	n.burst.mu.Unlock()
+
+	n.flushes.Wait()
+}
+
+// markHeld records that an emoji is held back, so it can still be announced
+// if the process stops before its digest or pack goes out. The caller must
+// hold the lock of the buffer holding it, so the flush can't unmark it first.
+func (n *Notifier) markHeld(emoji *store.Emoji) {
+	emoji.HeldAt = time.Now()
+	n.saveEmoji(emoji)
+}
+
+// unmarkHeld clears the held mark of a batch once it has been announced,
+// dead-lettered or dropped. The stored state is reloaded, as the emojis may
+// have been removed or renamed while held.
+func (n *Notifier) unmarkHeld(batch []*store.Emoji) {
+	n.eventsMutex.Lock()
+	defer n.eventsMutex.Unlock()
+
+	for _, emoji := range batch {
+		emoji.HeldAt = time.Time{}
+		stored, err := n.store.GetEmoji(emoji.Name)
+		if err != nil || stored.HeldAt.IsZero() {
+			continue
+		}
+		stored.HeldAt = time.Time{}
+		if err := n.store.PutEmoji(stored); err != nil {
+			log.Error().Err(err).Str("emoji", emoji.Name).Msg("failed to save emoji state")
+		}
+	}
+}
+
+// ResumeHeld announces the additions that were held for a digest or pack when
+// the previous run stopped, before they were announced. They go out as
+// digests, since the rest of their family or burst may be long gone, and are
+// checked against the rules again, as which one they matched isn't kept.
+func (n *Notifier) ResumeHeld() {
+	emojis, err := n.store.ListEmojis()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list emojis held by the previous run")
+		return
+	}
+
+	var held []heldEmoji
+	var stale []*store.Emoji
+	for _, emoji := range emojis {
+		if emoji.HeldAt.IsZero() {
+			continue
+		}
+		d := &delivery{kind: announceAdd, emoji: emoji}
+		if !emoji.Active || !n.applyRules(n.ctx, d, "add") {
+			stale = append(stale, emoji)
+			continue
+		}
+		held = append(held, heldEmoji{emoji: emoji, rule: d.rule})
+	}
+	n.unmarkHeld(stale)
+	if len(held) == 0 {
+		return
+	}
+	log.Info().Int("emojis", len(held)).Msg("announcing emojis held by the previous run")
+
+	n.burst.mu.Lock()
+	defer n.burst.mu.Unlock()
+	for len(held) > 0 {
+		size := min(len(held), maxDigestSize)
+		n.startFlush(held[:size], 0)
+		held = held[size:]
+	}
+}
+
+// emojiNames returns the names of a batch of emojis
+func emojiNames(emojis []*store.Emoji) []string {
+	names := make([]string, len(emojis))
+	for i, e := range emojis {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// buildDigestAnnouncement builds a single Slack message for a burst of new
+// emojis, with their images laid out as a grid
+func buildDigestAnnouncement(t *Templates, batch []*store.Emoji, sentence string) slack.MessageContent {
+	data := emojiTemplateData(batch[0], time.Now())
+	data.Names = emojiNames(batch)
+	data.ImageURLs = make([]string, len(batch))
+	for i, e := range batch {
+		data.ImageURLs[i] = e.URL
+	}
+	data.Count = len(batch)
+	data.Sentence = sentence
+	content := t.render(TemplateDigest, data)
+	content.Grid = emojiGridImages(data.Names, data.ImageURLs)
+	return content
+}
+
+// emojiGridImages pairs emoji names with their images for a grid. Emojis
+// without a known image are listed by name only.
+func emojiGridImages(names, urls []string) []slack.Image {
+	images := make([]slack.Image, len(names))
+	for i, name := range names {
+		images[i] = slack.Image{AltText: fmt.Sprintf("the %s emoji", name), Title: fmt.Sprintf("`%s`", name)}
+		if i < len(urls) {
+			images[i].URL = urls[i]
+		}
+	}
+	return images
+}
+
+// emojiGrid lays out emojis with their names, a few to a line
+func emojiGrid(names []string) string {
+	var grid strings.Builder
+	for i, name := range names {
+		switch {
+		case i == 0:
+		case i%digestGridWidth == 0:
+			grid.WriteString("\n")
+		default:
+			grid.WriteString("   ")
+		}
+		fmt.Fprintf(&grid, ":%s: `%s`", name, name)
+	}
+	return grid.String()
+}
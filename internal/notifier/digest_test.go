@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// TestBufferBurst checks that additions are held from the start of a possible
+// burst, and that the first one's window is only extended once the burst
+// passes the threshold
+func TestBufferBurst(t *testing.T) {
+	st, err := store.Open("memory", "")
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	defer st.Close()
+
+	n := &Notifier{store: st}
+	n.burst.threshold = 2
+	n.burst.window = time.Hour
+	defer func() {
+		n.burst.mu.Lock()
+		n.burst.takePending()
+		n.burst.mu.Unlock()
+	}()
+
+	steps := []struct {
+		name     string
+		extended bool
+	}{
+		{"blob_wave", true},
+		{"party_parrot", false},
+		{"catjam", true},
+		{"blob_dance", true},
+	}
+	for _, step := range steps {
+		before := n.burst.timer
+		if !n.bufferBurst(&store.Emoji{Name: step.name}, nil) {
+			t.Fatalf("bufferBurst(%q) didn't hold the addition", step.name)
+		}
+		if extended := n.burst.timer != before; extended != step.extended {
+			t.Errorf("bufferBurst(%q) extended the window = %v, want %v", step.name, extended, step.extended)
+		}
+
+		stored, err := st.GetEmoji(step.name)
+		if err != nil {
+			t.Fatalf("loading %q: %v", step.name, err)
+		}
+		if stored.HeldAt.IsZero() {
+			t.Errorf("%q wasn't marked as held", step.name)
+		}
+	}
+	if got := len(n.burst.pending); got != len(steps) {
+		t.Errorf("%d additions pending, want %d", got, len(steps))
+	}
+}
@@ -0,0 +1,186 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	slackapi "github.com/slack-go/slack"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ErrorClass groups delivery failures that share a retry policy
+type ErrorClass string
+
+const (
+	ClassRateLimited     ErrorClass = "rate_limited"
+	ClassAuth            ErrorClass = "auth"
+	ClassContentFiltered ErrorClass = "content_filtered"
+	ClassTransient       ErrorClass = "transient"
+	ClassPermanent       ErrorClass = "permanent"
+)
+
+// Sentinel errors matching each class with errors.Is
+var (
+	ErrRateLimited     = errors.New("rate limited")
+	ErrAuth            = errors.New("authentication failed")
+	ErrContentFiltered = errors.New("content filtered")
+	ErrTransient       = errors.New("transient failure")
+	ErrPermanent       = errors.New("permanent failure")
+)
+
+var classErrors = map[ErrorClass]error{
+	ClassRateLimited:     ErrRateLimited,
+	ClassAuth:            ErrAuth,
+	ClassContentFiltered: ErrContentFiltered,
+	ClassTransient:       ErrTransient,
+	ClassPermanent:       ErrPermanent,
+}
+
+// Delivery stages an announcement can fail in
+const (
+	StageGenerate = "generate"
+	StageSend     = "send"
+)
+
+// DeliveryError is a classified failure to generate or send an announcement
+type DeliveryError struct {
+	Class      ErrorClass
+	Stage      string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("%s failed (%s): %v", e.Stage, e.Class, e.Err)
+}
+
+// Unwrap exposes both the class sentinel and the underlying error
+func (e *DeliveryError) Unwrap() []error {
+	return []error{classErrors[e.Class], e.Err}
+}
+
+// slack API errors that retrying can't fix
+var (
+	slackAuthErrors = map[string]bool{
+		"invalid_auth":     true,
+		"not_authed":       true,
+		"account_inactive": true,
+		"token_revoked":    true,
+		"token_expired":    true,
+		"missing_scope":    true,
+		"not_in_channel":   true,
+	}
+	slackTransientErrors = map[string]bool{
+		"internal_error":      true,
+		"fatal_error":         true,
+		"service_unavailable": true,
+		"request_timeout":     true,
+	}
+)
+
+// classify wraps err in a DeliveryError for the given stage
+func classify(stage string, err error) *DeliveryError {
+	var derr *DeliveryError
+	if errors.As(err, &derr) {
+		return derr
+	}
+
+	d := &DeliveryError{Class: ClassTransient, Stage: stage, Err: err}
+
+	var (
+		rateLimited *slackapi.RateLimitedError
+		slackErr    slackapi.SlackErrorResponse
+		statusErr   slackapi.StatusCodeError
+		llmErr      *llms.Error
+		netErr      net.Error
+	)
+
+	switch {
+	case errors.As(err, &rateLimited):
+		d.Class = ClassRateLimited
+		d.RetryAfter = rateLimited.RetryAfter
+
+	case errors.As(err, &slackErr):
+		switch {
+		case slackErr.Err == "ratelimited":
+			d.Class = ClassRateLimited
+		case slackAuthErrors[slackErr.Err]:
+			d.Class = ClassAuth
+		case slackTransientErrors[slackErr.Err]:
+			d.Class = ClassTransient
+		default:
+			// channel_not_found, is_archived, msg_too_long, invalid_blocks...
+			d.Class = ClassPermanent
+		}
+
+	case errors.As(err, &statusErr):
+		d.Class = classifyStatus(statusErr.Code)
+
+	case errors.As(err, &llmErr):
+		switch llmErr.Code {
+		case llms.ErrCodeRateLimit:
+			d.Class = ClassRateLimited
+		case llms.ErrCodeAuthentication, llms.ErrCodeQuotaExceeded:
+			d.Class = ClassAuth
+		case llms.ErrCodeContentFilter:
+			d.Class = ClassContentFiltered
+		case llms.ErrCodeInvalidRequest, llms.ErrCodeTokenLimit, llms.ErrCodeResourceNotFound, llms.ErrCodeNotImplemented:
+			d.Class = ClassPermanent
+		default:
+			d.Class = ClassTransient
+		}
+
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		d.Class = ClassTransient
+	}
+
+	return d
+}
+
+func classifyStatus(code int) ErrorClass {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ClassRateLimited
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return ClassAuth
+	case code >= 500:
+		return ClassTransient
+	default:
+		return ClassPermanent
+	}
+}
+
+// RetryPolicy controls how often and how fast a class of failures is retried
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// defaultRetryPolicies retries what can recover on its own and gives up on the rest
+var defaultRetryPolicies = map[ErrorClass]RetryPolicy{
+	ClassRateLimited:     {MaxAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute},
+	ClassTransient:       {MaxAttempts: 5, BaseDelay: 2 * time.Second, MaxDelay: 1 * time.Minute},
+	ClassContentFiltered: {MaxAttempts: 3},
+	ClassAuth:            {MaxAttempts: 1},
+	ClassPermanent:       {MaxAttempts: 1},
+}
+
+// delay returns the wait before the next attempt, honoring a server-provided retry-after
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if retryAfter > d {
+		d = retryAfter
+	}
+	return d
+}
@@ -0,0 +1,57 @@
+package notifier
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// record appends an event to an emoji's history, logging any failure. History
+// is informational, so failing to record it never stops the change itself.
+func (n *Notifier) record(e store.HistoryEvent) {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	if err := n.store.AppendHistory(e.Emoji, e); err != nil {
+		log.Error().Err(err).Str("emoji", e.Emoji).Str("type", string(e.Type)).Msg("failed to record emoji history")
+	}
+}
+
+// addedHistoryType maps the announcement an added emoji needs to its history event
+func addedHistoryType(kind string, emoji *store.Emoji) store.HistoryType {
+	switch {
+	case emoji.AliasOf != "":
+		return store.HistoryAlias
+	case kind == announceReplace:
+		return store.HistoryReplaced
+	case kind == announceReturn:
+		return store.HistoryReturned
+	default:
+		return store.HistoryAdded
+	}
+}
+
+// recordAnnounced records a delivered announcement with a link to its message
+// in the history of every emoji it announced
+func (n *Notifier) recordAnnounced(ctx context.Context, d *delivery) {
+	link, err := n.slackClient.Permalink(ctx, slack.MessageRef{Channel: d.ref.Channel, Timestamp: d.ref.Timestamp})
+	if err != nil {
+		log.Debug().Err(err).Str("emoji", d.emoji.Name).Msg("failed to get announcement permalink")
+	}
+
+	for _, emoji := range d.emojis() {
+		n.record(store.HistoryEvent{
+			Type:      store.HistoryAnnounced,
+			Emoji:     emoji.Name,
+			Kind:      d.kind,
+			Text:      d.sentence,
+			Model:     d.model,
+			Message:   &store.MessageRef{Channel: d.ref.Channel, Timestamp: d.ref.Timestamp},
+			Permalink: link,
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// Action IDs of the buttons on announcements
+const (
+	actionRegenerate  = "regenerate"
+	actionEditCaption = "edit_caption"
+	actionDelete      = "delete"
+)
+
+const (
+	// editCaptionCallback identifies the modal for editing a caption
+	editCaptionCallback = "edit_caption"
+	captionInput        = "caption"
+
+	// postedRetention is how long the buttons on an announcement keep working
+	postedRetention = 30 * 24 * time.Hour
+)
+
+// WithButtons adds buttons to announcements with a generated sentence for
+// regenerating it or editing it by hand. Admins, a list of Slack user IDs,
+// also get a button to delete the announcement; it isn't shown without any.
+// Only admins and editors may change a sentence, anyone if editors holds "*"
+// and no one if neither list has anyone. Clicks only arrive over Socket Mode.
+func WithButtons(enabled bool, admins, editors []string) Option {
+	return func(n *Notifier) {
+		n.buttons = enabled
+		n.admins = admins
+		n.editors = editors
+	}
+}
+
+// canEdit reports whether a user may regenerate or edit an announcement's sentence
+func (n *Notifier) canEdit(user string) bool {
+	return slices.Contains(n.editors, "*") || slices.Contains(n.admins, user) || slices.Contains(n.editors, user)
+}
+
+// announcementButtons are the buttons shown on an announcement
+func (n *Notifier) announcementButtons() []slack.Button {
+	buttons := []slack.Button{
+		{ActionID: actionRegenerate, Text: "Regenerate"},
+		{ActionID: actionEditCaption, Text: "Edit caption"},
+	}
+	if len(n.admins) > 0 {
+		buttons = append(buttons, slack.Button{
+			ActionID: actionDelete,
+			Text:     "Delete",
+			Danger:   true,
+			Confirm:  "This deletes the announcement for everyone. Only admins can do this.",
+		})
+	}
+	return buttons
+}
+
+// hasButtons reports whether a delivery's message shows buttons
+func (n *Notifier) hasButtons(d *delivery) bool {
+	return n.buttons && d.sentence != ""
+}
+
+// rememberPosted keeps what's needed to rebuild a delivered announcement when
+// one of its buttons is clicked
+func (n *Notifier) rememberPosted(d *delivery) {
+	if !n.hasButtons(d) {
+		return
+	}
+	p := &store.PostedMessage{Channel: d.ref.Channel, Timestamp: d.ref.Timestamp, PostedAt: time.Now()}
+	p.ID = d.id()
+	p.CreatedAt = p.PostedAt
+	d.describe(&p.OutboxEntry)
+	if err := n.store.PutPosted(p); err != nil {
+		log.Error().Err(err).Str("emoji", d.emoji.Name).Msg("failed to save posted announcement")
+	}
+}
+
+// HandleInteraction handles clicks on the buttons of announcements and the
+// submission of the modal for editing a caption. It is called as soon as they
+// arrive, so everything that calls Slack or the LLM runs in the background.
+func (n *Notifier) HandleInteraction(ctx context.Context, in slack.Interaction) {
+	logger := log.With().Str("type", in.Type).Str("action", in.ActionID).Str("user", in.UserID).Logger()
+	if n.shuttingDown() {
+		logger.Info().Msg("shutting down, ignoring interaction")
+		return
+	}
+
+	if in.Type == slack.InteractionViewSubmission {
+		channel, ts, _ := strings.Cut(in.Metadata, ":")
+		in.Message = slack.MessageRef{Channel: channel, Timestamp: ts}
+	}
+	editing := in.ActionID == actionRegenerate || in.ActionID == actionEditCaption || in.CallbackID == editCaptionCallback
+
+	switch {
+	case editing && !n.canEdit(in.UserID):
+		logger.Warn().Msg("refusing to change announcement for a user who isn't an editor")
+		n.inBackground(func() { n.tellUser(n.ctx, in, "Sorry, only editors can change announcements.") })
+	case in.Type == slack.InteractionBlockActions && in.ActionID == actionRegenerate:
+		n.inBackground(func() { n.regenerate(n.ctx, in) })
+	case in.Type == slack.InteractionBlockActions && in.ActionID == actionEditCaption:
+		n.inBackground(func() { n.openCaptionEditor(n.ctx, in) })
+	case in.Type == slack.InteractionBlockActions && in.ActionID == actionDelete:
+		n.inBackground(func() { n.deleteAnnouncement(n.ctx, in) })
+	case in.Type == slack.InteractionViewSubmission && in.CallbackID == editCaptionCallback:
+		n.inBackground(func() { n.editCaption(n.ctx, in) })
+	default:
+		logger.Debug().Msg("ignoring unknown interaction")
+	}
+}
+
+// regenerate replaces an announcement's sentence with a newly generated one
+func (n *Notifier) regenerate(ctx context.Context, in slack.Interaction) {
+	d, ok := n.posted(ctx, in)
+	if !ok {
+		return
+	}
+	d.sentence, d.model = "", ""
+	n.rewrite(ctx, in, d, "regenerate")
+}
+
+// openCaptionEditor opens a modal for editing an announcement's sentence. The
+// click is handled as soon as it arrives, as its trigger expires within seconds.
+func (n *Notifier) openCaptionEditor(ctx context.Context, in slack.Interaction) {
+	d, ok := n.posted(ctx, in)
+	if !ok {
+		return
+	}
+	err := n.slackClient.OpenModal(ctx, in.TriggerID, slack.Modal{
+		CallbackID: editCaptionCallback,
+		Metadata:   in.Message.Channel + ":" + in.Message.Timestamp,
+		Title:      "Edit caption",
+		Submit:     "Save",
+		Label:      fmt.Sprintf("Caption for :%s:", d.emoji.Name),
+		InputID:    captionInput,
+		Value:      d.sentence,
+		Multiline:  true,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("emoji", d.emoji.Name).Msg("failed to open caption editor")
+		n.tellUser(ctx, in, "Sorry, the caption editor failed to open: "+err.Error())
+	}
+}
+
+// editCaption replaces an announcement's sentence with one written by hand
+func (n *Notifier) editCaption(ctx context.Context, in slack.Interaction) {
+	caption := strings.TrimSpace(in.Inputs[captionInput])
+	if caption == "" {
+		return
+	}
+	d, ok := n.posted(ctx, in)
+	if !ok {
+		return
+	}
+	d.sentence, d.model = caption, ""
+	n.rewrite(ctx, in, d, "edit")
+}
+
+// rewrite updates an announcement's message with the delivery's sentence,
+// generating one if it has none, and remembers the new sentence
+func (n *Notifier) rewrite(ctx context.Context, in slack.Interaction, d *delivery, verb string) {
+	logger := log.With().Str("emoji", d.emoji.Name).Str("user", in.UserID).Logger()
+
+	content, err := n.buildMessage(ctx, d)
+	if err == nil && d.sentence == "" {
+		err = errors.New("no sentence was written for it")
+	}
+	if err == nil {
+		err = n.slackClient.UpdateMessage(ctx, in.Message, content)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msgf("failed to %s announcement", verb)
+		n.tellUser(ctx, in, fmt.Sprintf("Sorry, I couldn't %s the announcement: %v", verb, err))
+		return
+	}
+	logger.Info().Str("sentence", d.sentence).Msgf("announcement caption changed by %s", verb)
+
+	n.rememberPosted(d)
+	for _, emoji := range d.emojis() {
+		if n.updateRecorded(emoji, d.ref, d.sentence, false) {
+			n.saveEmoji(emoji)
+		}
+		n.record(store.HistoryEvent{
+			Type:    store.HistoryEdited,
+			Emoji:   emoji.Name,
+			Kind:    d.kind,
+			Text:    d.sentence,
+			Model:   d.model,
+			Message: &store.MessageRef{Channel: d.ref.Channel, Timestamp: d.ref.Timestamp},
+			User:    in.UserID,
+		})
+	}
+}
+
+// deleteAnnouncement deletes an announcement, if an admin asked for it
+func (n *Notifier) deleteAnnouncement(ctx context.Context, in slack.Interaction) {
+	if !slices.Contains(n.admins, in.UserID) {
+		log.Warn().Str("user", in.UserID).Msg("refusing to delete announcement for a user who isn't an admin")
+		n.tellUser(ctx, in, "Sorry, only admins can delete announcements.")
+		return
+	}
+	d, ok := n.posted(ctx, in)
+	if !ok {
+		return
+	}
+
+	if err := n.slackClient.DeleteMessage(ctx, in.Message); err != nil {
+		log.Error().Err(err).Str("emoji", d.emoji.Name).Msg("failed to delete announcement")
+		n.tellUser(ctx, in, "Sorry, I couldn't delete the announcement: "+err.Error())
+		return
+	}
+	log.Info().Str("emoji", d.emoji.Name).Str("user", in.UserID).Msg("announcement deleted")
+
+	if err := n.store.DeletePosted(in.Message.Channel, in.Message.Timestamp); err != nil {
+		log.Error().Err(err).Str("emoji", d.emoji.Name).Msg("failed to forget deleted announcement")
+	}
+	for _, emoji := range d.emojis() {
+		if n.updateRecorded(emoji, d.ref, "", true) {
+			n.saveEmoji(emoji)
+		}
+		n.record(store.HistoryEvent{
+			Type:    store.HistoryDeleted,
+			Emoji:   emoji.Name,
+			Kind:    d.kind,
+			Message: &store.MessageRef{Channel: d.ref.Channel, Timestamp: d.ref.Timestamp},
+			User:    in.UserID,
+		})
+	}
+}
+
+// posted rebuilds the delivery of the announcement an interaction is about,
+// telling the user if it can't be
+func (n *Notifier) posted(ctx context.Context, in slack.Interaction) (*delivery, bool) {
+	p, err := n.store.GetPosted(in.Message.Channel, in.Message.Timestamp)
+	if errors.Is(err, store.ErrNotFound) {
+		n.tellUser(ctx, in, "Sorry, this announcement is too old to change.")
+		return nil, false
+	}
+	if err == nil {
+		var d *delivery
+		if d, err = n.restore(&p.OutboxEntry); err == nil {
+			d.ref = in.Message
+			return d, true
+		}
+	}
+	log.Error().Err(err).Str("channel", in.Message.Channel).Str("ts", in.Message.Timestamp).Msg("failed to load posted announcement")
+	n.tellUser(ctx, in, "Sorry, something went wrong loading this announcement.")
+	return nil, false
+}
+
+// updateRecorded changes the sentence an emoji's announcement in the given
+// message was recorded with, or forgets the announcement, reporting whether
+// the emoji has one there
+func (n *Notifier) updateRecorded(emoji *store.Emoji, ref slack.MessageRef, sentence string, forget bool) bool {
+	if a := emoji.Announcement; a != nil && a.Channel == ref.Channel && a.Timestamp == ref.Timestamp {
+		if forget {
+			emoji.Announcement = nil
+		}
+		emoji.Sentence = sentence
+		return true
+	}
+
+	for i, c := range emoji.Copies {
+		if c.Channel != ref.Channel || c.Timestamp != ref.Timestamp {
+			continue
+		}
+		if forget {
+			emoji.Copies = slices.Delete(emoji.Copies, i, i+1)
+		} else {
+			emoji.Copies[i].Sentence = sentence
+		}
+		return true
+	}
+	return false
+}
+
+// tellUser posts a message only the user behind an interaction can see
+func (n *Notifier) tellUser(ctx context.Context, in slack.Interaction, text string) {
+	if in.Message.Channel == "" {
+		return
+	}
+	if err := n.slackClient.PostEphemeral(ctx, in.Message.Channel, in.UserID, text); err != nil {
+		log.Error().Err(err).Str("user", in.UserID).Msg("failed to post ephemeral message")
+	}
+}
+
+// prunePosted forgets announcements whose buttons no longer work
+func (n *Notifier) prunePosted() {
+	removed, err := n.store.PrunePosted(time.Now().Add(-postedRetention))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to clean up posted announcements")
+		return
+	}
+	log.Debug().Int("removed", removed).Msg("cleaned up posted announcements")
+}
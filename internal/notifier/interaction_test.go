@@ -0,0 +1,31 @@
+package notifier
+
+import "testing"
+
+// TestCanEdit checks who may regenerate or edit an announcement's sentence
+func TestCanEdit(t *testing.T) {
+	tests := []struct {
+		name    string
+		admins  []string
+		editors []string
+		user    string
+		want    bool
+	}{
+		{name: "no one without admins or editors", user: "U1"},
+		{name: "admin", admins: []string{"UADMIN"}, user: "UADMIN", want: true},
+		{name: "not an admin", admins: []string{"UADMIN"}, user: "U1"},
+		{name: "editor", admins: []string{"UADMIN"}, editors: []string{"U1"}, user: "U1", want: true},
+		{name: "not an editor", editors: []string{"U1"}, user: "U2"},
+		{name: "admin without being an editor", admins: []string{"UADMIN"}, editors: []string{"U1"}, user: "UADMIN", want: true},
+		{name: "wildcard", admins: []string{"UADMIN"}, editors: []string{"*"}, user: "U2", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &Notifier{admins: tt.admins, editors: tt.editors}
+			if got := n.canEdit(tt.user); got != tt.want {
+				t.Errorf("canEdit(%q) = %v, want %v", tt.user, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package notifier
+
+import "fmt"
+
+// NoticeMode decides whether and how a change other than an addition is announced
+type NoticeMode string
+
+const (
+	// NoticeOff doesn't announce the change
+	NoticeOff NoticeMode = "off"
+	// NoticeText posts a short fixed notice
+	NoticeText NoticeMode = "text"
+	// NoticeLLM adds an LLM-written sentence to the notice
+	NoticeLLM NoticeMode = "llm"
+)
+
+// ParseNoticeMode validates a notice mode name
+func ParseNoticeMode(value string) (NoticeMode, error) {
+	switch mode := NoticeMode(value); mode {
+	case NoticeOff, NoticeText, NoticeLLM:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported notice mode %q", value)
+	}
+}
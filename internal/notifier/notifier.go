@@ -3,9 +3,11 @@ package notifier
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -14,49 +16,159 @@ import (
 
 	"github.com/particledecay/slackmoji-notifier/pkg/llm"
 	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
 )
 
-const eventThreshold = 1 * time.Minute
+const (
+	defaultDedupeWindow = 1 * time.Hour
+	cleanupInterval     = 1 * time.Minute
+)
 
 type Notifier struct {
-	slackClient     slack.ClientInterface
-	llmClient       llm.LLMClient
-	processedEvents map[string]time.Time
-	knownEmojis     map[string]bool
-	eventsMutex     sync.Mutex
-	logOnly         bool
+	slackClient  slack.ClientInterface
+	llmClient    llm.LLMClient
+	store        *store.Store
+	eventsMutex  sync.Mutex
+	logOnly      bool
+	dedupeWindow time.Duration
+	workers      int
+	queueSize    int
+	queuePolicy  BackpressurePolicy
+	queue        *workQueue
+	aliasMode    AliasMode
+	renameNotice NoticeMode
+
+	removalNotice  NoticeMode
+	removalChannel string
+	replaceWindow  time.Duration
+	burst          burstBuffer
+	packs          packBuffer
+	flushes        sync.WaitGroup
+	dailyThread    bool
+	threadMu       sync.Mutex
+	quietHours     map[string]QuietHours
+	heldMu         sync.Mutex
+	heldWake       chan struct{}
+	templates      *Templates
+	destinations   []*Destination
+	rules          []*Rule
+	buttons        bool
+	admins         []string
+	editors        []string
+
+	// ctx bounds all work started by the notifier and is canceled when a
+	// shutdown runs out of time
+	ctx          context.Context
+	cancel       context.CancelFunc
+	draining     chan struct{}
+	shutdownOnce sync.Once
+	deadLettered atomic.Int64
+	abandonedMu  sync.Mutex
+	abandoned    []string
 }
 
-func New(llmClient llm.LLMClient, logOnly bool) *Notifier {
+type Option func(*Notifier)
+
+func New(llmClient llm.LLMClient, st *store.Store, options ...Option) *Notifier {
 	n := &Notifier{
-		llmClient:       llmClient,
-		processedEvents: make(map[string]time.Time),
-		knownEmojis:     make(map[string]bool),
-		logOnly:         logOnly,
+		llmClient:     llmClient,
+		store:         st,
+		dedupeWindow:  defaultDedupeWindow,
+		workers:       defaultWorkers,
+		queueSize:     defaultQueueSize,
+		queuePolicy:   defaultQueuePolicy,
+		aliasMode:     defaultAliasMode,
+		renameNotice:  NoticeOff,
+		removalNotice: NoticeOff,
+		replaceWindow: defaultReplaceWindow,
+		draining:      make(chan struct{}),
+		heldWake:      make(chan struct{}, 1),
+		templates:     builtinTemplates,
+		destinations:  []*Destination{{}},
 	}
+	n.burst.threshold = defaultBurstThreshold
+	n.burst.window = defaultBurstWindow
+	n.packs.window = defaultPackWindow
+	n.packs.minSize = defaultPackMinSize
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+
+	for _, option := range options {
+		option(n)
+	}
+
+	n.queue = newWorkQueue(n.workers, n.queueSize, n.queuePolicy, n.runJob, n.abandon)
+	n.queue.start()
+	n.queue.startReporter(n.ctx)
+
 	n.startCleanupRoutine()
 	return n
 }
 
+// WithLogOnly logs announcements instead of generating and sending them
+func WithLogOnly(logOnly bool) Option {
+	return func(n *Notifier) {
+		n.logOnly = logOnly
+	}
+}
+
+// WithDedupeWindow sets how long processed events are remembered for deduplication
+func WithDedupeWindow(window time.Duration) Option {
+	return func(n *Notifier) {
+		if window > 0 {
+			n.dedupeWindow = window
+		}
+	}
+}
+
+// WithWorkers sets how many emojis are processed concurrently
+func WithWorkers(workers int) Option {
+	return func(n *Notifier) {
+		if workers > 0 {
+			n.workers = workers
+		}
+	}
+}
+
+// WithQueue sets how many jobs may wait for a worker and what happens when the queue is full
+func WithQueue(size int, policy BackpressurePolicy) Option {
+	return func(n *Notifier) {
+		if size > 0 {
+			n.queueSize = size
+		}
+		if policy != "" {
+			n.queuePolicy = policy
+		}
+	}
+}
+
+// QueueStats reports the depth of the work queue and how many jobs were processed or dropped
+func (n *Notifier) QueueStats() QueueStats {
+	return n.queue.stats()
+}
+
 func (n *Notifier) SetSlackClient(client slack.ClientInterface) {
 	n.slackClient = client
 }
 
-func (n *Notifier) HandleEvent(event interface{}) {
+// HandleEvent queues the work described by a Slack event. ctx only bounds
+// queueing; the queued work runs until it finishes or Shutdown cancels it.
+func (n *Notifier) HandleEvent(ctx context.Context, event interface{}) {
 	log.Debug().Interface("event", event).Msgf("notifier received event of type %T", event)
 
-	ctx := context.Background()
-
 	switch evt := event.(type) {
 	case socketmode.Event:
 		log.Debug().Msg("event is a socketmode event")
 		n.handleSocketModeEvent(ctx, evt)
+	case slack.HTTPEvent:
+		log.Debug().Msg("event is an HTTP Events API event")
+		n.handleHTTPEvent(ctx, evt)
 	default:
 		log.Debug().Msgf("unhandled event type %T", event)
 	}
 }
 
-type SocketModePayload struct {
+// EventPayload holds the envelope details of an Events API callback
+type EventPayload struct {
 	EventID      string `json:"event_id"`
 	EventTime    int64  `json:"event_time"`
 	RetryAttempt int    `json:"retry_attempt"`
@@ -66,11 +178,15 @@ func (n *Notifier) handleSocketModeEvent(ctx context.Context, event socketmode.E
 	log.Debug().Str("type", string(event.Type)).Msg("handling socketmode event")
 
 	if event.Type == socketmode.EventTypeEventsAPI {
-		var payload SocketModePayload
+		var payload EventPayload
 		if err := json.Unmarshal(event.Request.Payload, &payload); err != nil {
 			log.Error().Err(err).Msg("failed to unmarshal payload")
 			return
 		}
+		if event.Request.RetryAttempt > payload.RetryAttempt {
+			// socket mode reports retries on the envelope rather than the payload
+			payload.RetryAttempt = event.Request.RetryAttempt
+		}
 
 		eventsAPIEvent, ok := event.Data.(slackevents.EventsAPIEvent)
 		if !ok {
@@ -78,140 +194,264 @@ func (n *Notifier) handleSocketModeEvent(ctx context.Context, event socketmode.E
 			return
 		}
 
-		if eventsAPIEvent.Type == slackevents.CallbackEvent {
-			innerEvent := eventsAPIEvent.InnerEvent
-			switch ev := innerEvent.Data.(type) {
-			case *slackevents.EmojiChangedEvent:
-				// slack sends emoji changes multiple times for some reason so we ignore older ones
-				eventTime := time.Unix(payload.EventTime, 0)
-				if time.Since(eventTime) > eventThreshold {
-					log.Debug().
-						Str("event_id", payload.EventID).
-						Time("event_time", eventTime).
-						Str("emoji", ev.Name).
-						Msg("ignoring old event")
-					return
-				}
-
-				// even within the threshold sometimes we get retries, so we ignore those too
-				if payload.RetryAttempt > 0 {
-					log.Debug().
-						Str("event_id", payload.EventID).
-						Int("retry_attempt", payload.RetryAttempt).
-						Str("emoji", ev.Name).
-						Msg("ignoring retry event")
-					return
-				}
-
-				switch ev.Subtype {
-				case "add":
-					n.handleNewEmoji(ctx, ev.Name, ev.Value)
-				case "remove":
-					n.handleRemovedEmoji(ev.Name)
-				}
-			default:
-				log.Debug().Str("type", innerEvent.Type).Msg("unhandled inner event type")
-			}
-		}
+		n.handleEventsAPIEvent(ctx, payload, eventsAPIEvent)
 	} else {
 		log.Debug().Str("type", string(event.Type)).Msg("event is not an EventsAPI event, skipping")
 	}
 }
 
-func (n *Notifier) handleRemovedEmoji(name string) {
-	n.eventsMutex.Lock()
-	defer n.eventsMutex.Unlock()
+func (n *Notifier) handleHTTPEvent(ctx context.Context, event slack.HTTPEvent) {
+	payload := EventPayload{RetryAttempt: event.RetryAttempt}
+	if cb, ok := event.Event.Data.(*slackevents.EventsAPICallbackEvent); ok {
+		payload.EventID = cb.EventID
+		payload.EventTime = int64(cb.EventTime)
+	}
 
-	val, ok := n.knownEmojis[name]
-	if !ok {
-		log.Debug().Str("emoji", name).Msg("ignoring unaccounted emoji")
+	n.handleEventsAPIEvent(ctx, payload, event.Event)
+}
+
+// handleEventsAPIEvent handles a callback event from either transport
+func (n *Notifier) handleEventsAPIEvent(ctx context.Context, payload EventPayload, eventsAPIEvent slackevents.EventsAPIEvent) {
+	if eventsAPIEvent.Type != slackevents.CallbackEvent {
 		return
 	}
-	if !val {
-		log.Debug().Str("emoji", name).Msg("ignoring already deleted emoji")
+
+	innerEvent := eventsAPIEvent.InnerEvent
+	switch ev := innerEvent.Data.(type) {
+	case *slackevents.EmojiChangedEvent:
+		// slack redelivers events (retries, reconnects) so only handle each one once
+		if !n.markProcessed(payload, ev) {
+			return
+		}
+
+		switch ev.Subtype {
+		case "add":
+			n.push(ctx, job{kind: jobAdd, name: ev.Name, value: ev.Value})
+		case "remove":
+			// bulk deletions list every removed name
+			for _, name := range removedNames(ev) {
+				n.push(ctx, job{kind: jobRemove, name: name})
+			}
+		case "rename":
+			n.push(ctx, job{kind: jobRename, name: ev.NewName, oldName: ev.OldName, value: ev.Value})
+		}
+	default:
+		log.Debug().Str("type", innerEvent.Type).Msg("unhandled inner event type")
+	}
+}
+
+// markProcessed records the event as processed and reports whether it is new.
+// Events are deduplicated by event ID and by the emoji change they describe,
+// since Slack may deliver the same change more than once.
+func (n *Notifier) markProcessed(payload EventPayload, ev *slackevents.EmojiChangedEvent) bool {
+	names := ev.Names
+	if ev.Name != "" {
+		names = append([]string{ev.Name}, names...)
+	}
+	if ev.OldName != "" {
+		names = []string{ev.OldName, ev.NewName}
+	}
+
+	// event_ts identifies the change itself, whichever envelope it was delivered in
+	keys := []string{fmt.Sprintf("emoji:%s:%s:%s", ev.Subtype, strings.Join(names, ","), ev.EventTimeStamp)}
+	if payload.EventID != "" {
+		keys = append([]string{"event:" + payload.EventID}, keys...)
+	}
+
+	logger := log.With().
+		Str("event_id", payload.EventID).
+		Str("subtype", ev.Subtype).
+		Strs("emojis", names).
+		Int("retry_attempt", payload.RetryAttempt).
+		Logger()
+
+	now := time.Now()
+	for _, key := range keys {
+		isNew, err := n.store.MarkProcessed(key, now, n.dedupeWindow)
+		if err != nil {
+			// better to risk a duplicate than to drop the event
+			logger.Error().Err(err).Str("key", key).Msg("failed to record processed event")
+			continue
+		}
+		if !isNew {
+			logger.Debug().Str("key", key).Msg("ignoring duplicate event")
+			return false
+		}
+	}
+
+	if payload.RetryAttempt > 0 {
+		logger.Debug().Msg("processing retried event")
+	}
+	return true
+}
+
+// push queues a job. Jobs the queue refuses or drops are recorded as abandoned.
+func (n *Notifier) push(ctx context.Context, j job) {
+	n.queue.push(ctx, j)
+}
+
+// runJob handles a single queued job on a worker
+func (n *Notifier) runJob(j job) {
+	if n.ctx.Err() != nil {
+		n.abandon(j)
 		return
 	}
 
-	log.Info().Str("emoji", name).Msg("removing emoji from known emojis")
-	n.knownEmojis[name] = false
+	switch j.kind {
+	case jobAdd:
+		n.handleNewEmoji(n.ctx, j.name, j.value)
+	case jobRemove:
+		n.handleRemovedEmoji(n.ctx, j.name)
+	case jobRename:
+		n.handleRenamedEmoji(n.ctx, j.oldName, j.name, j.value)
+	}
 }
 
 func (n *Notifier) handleNewEmoji(ctx context.Context, name, value string) {
-	n.eventsMutex.Lock()
-	defer n.eventsMutex.Unlock()
-
-	if n.knownEmojis[name] {
-		log.Debug().Str("emoji", name).Msg("ignoring known emoji")
+	d, ok := n.claimNewEmoji(name, value)
+	if !ok {
 		return
 	}
-	n.knownEmojis[name] = true
 
-	log.Info().Str("emoji", name).Msg("handling new emoji")
+	switch {
+	case d.emoji.AliasOf != "":
+		if !n.handleNewAlias(name, d.emoji.AliasOf) {
+			return
+		}
+	case d.kind == announceReplace:
+		log.Info().Str("emoji", name).Msg("handling replaced emoji image")
+	case d.kind == announceReturn:
+		log.Info().Str("emoji", name).Msg("handling re-added emoji")
+	default:
+		log.Info().Str("emoji", name).Msg("handling new emoji")
+	}
+
+	n.lookUpUploader(ctx, d.emoji)
+	if !n.applyRules(ctx, d, "add") {
+		return
+	}
 
 	if n.logOnly {
 		log.Info().
 			Str("emoji", name).
+			Str("kind", d.kind).
 			Msg("logOnly mode: would have generated sentence and sent Slack message")
 		return
 	}
 
-	sentence, err := n.llmClient.GenerateCompletion(ctx, "emoji name: "+name, false)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to generate sentence")
-		n.knownEmojis[name] = false
+	if d.kind == announceReplace {
+		n.markAnnouncementReplaced(ctx, d.emoji)
+	}
+
+	// families and bursts are announced together once they are complete, with
+	// the others that matched the same rule
+	if d.kind == announceAdd && d.emoji.AliasOf == "" && (n.holdForPack(d.emoji, d.rule) || n.bufferBurst(d.emoji, d.rule)) {
 		return
 	}
 
-	log.Debug().Str("sentence", sentence).Msg("generated sentence for new emoji")
+	// on failure the emoji stays known so a redelivered event doesn't announce
+	// it twice; the outbox holds it until an operator retries or drops it
+	n.deliver(ctx, d)
+}
 
-	// Construct the full-size image URL
-	fullSizeImageURL := value
-	if !strings.Contains(value, "?") {
-		fullSizeImageURL += "?size=512"
-	} else {
-		fullSizeImageURL += "&size=512"
-	}
+// claimNewEmoji records an added emoji and returns the announcement it still
+// needs, if any. Names seen before may be announced as a new image or a
+// return. The lock is only held for the state update, so slow LLM and Slack
+// calls don't block other workers.
+func (n *Notifier) claimNewEmoji(name, value string) (*delivery, bool) {
+	n.eventsMutex.Lock()
+	defer n.eventsMutex.Unlock()
 
-	messageText := fmt.Sprintf("*NEW EMOJI ADDED!*\n*Example Usage:*\n%s", sentence)
-	messageContent := slack.MessageContent{
-		Text: messageText,
-		Attachments: []slack.Attachment{
-			{
-				ImageURL: fullSizeImageURL,
-				Text:     name,
-			},
-		},
+	d := &delivery{kind: announceAdd}
+	emoji, err := n.store.GetEmoji(name)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		emoji = &store.Emoji{Name: name}
+	case err != nil:
+		log.Error().Err(err).Str("emoji", name).Msg("failed to load emoji state")
+		return nil, false
+	default:
+		d.kind = n.readdedKind(emoji, value)
+		if d.kind == "" {
+			log.Debug().Str("emoji", name).Msg("ignoring known emoji")
+			return nil, false
+		}
+		if d.kind == announceReplace {
+			d.previousURL = emoji.URL
+		}
 	}
 
-	log.Debug().Interface("messageContent", messageContent).Msg("sending message to Slack")
+	if d.kind != announceReplace {
+		emoji.AddedAt = time.Now()
+	}
+	emoji.URL = value
+	emoji.AliasOf, _ = parseAlias(value)
+	emoji.Active = true
+	emoji.HeldAt = time.Time{}
+	if err := n.store.PutEmoji(emoji); err != nil {
+		log.Error().Err(err).Str("emoji", name).Msg("failed to save emoji state")
+		return nil, false
+	}
+	n.record(store.HistoryEvent{
+		Type:    addedHistoryType(d.kind, emoji),
+		Emoji:   name,
+		AliasOf: emoji.AliasOf,
+		URL:     value,
+	})
+	d.emoji = emoji
+	return d, true
+}
 
-	if err := n.slackClient.SendMessage(messageContent); err != nil {
-		log.Error().Err(err).Msg("failed to send message to Slack")
-		n.knownEmojis[name] = false
-	} else {
-		log.Debug().Msg("message sent successfully to Slack")
+// saveEmoji persists emoji state, logging any failure
+// lookUpUploader records who uploaded an emoji, if Slack says and it isn't
+// known yet
+func (n *Notifier) lookUpUploader(ctx context.Context, emoji *store.Emoji) {
+	if emoji.AddedBy != "" || n.slackClient == nil {
+		return
+	}
+	uploader, err := n.slackClient.EmojiUploader(ctx, emoji.Name)
+	if err != nil {
+		log.Warn().Err(err).Str("emoji", emoji.Name).Msg("failed to look up emoji uploader")
+		return
+	}
+	if uploader == "" {
+		return
 	}
+	emoji.AddedBy = uploader
+	n.saveEmoji(emoji)
 }
 
-func (n *Notifier) cleanupProcessedEvents() {
+func (n *Notifier) saveEmoji(emoji *store.Emoji) {
 	n.eventsMutex.Lock()
 	defer n.eventsMutex.Unlock()
 
-	threshold := time.Now().Add(-eventThreshold)
-	for id, timestamp := range n.processedEvents {
-		if timestamp.Before(threshold) {
-			delete(n.processedEvents, id)
-		}
+	if err := n.store.PutEmoji(emoji); err != nil {
+		log.Error().Err(err).Str("emoji", emoji.Name).Msg("failed to save emoji state")
+	}
+}
+
+func (n *Notifier) cleanupProcessedEvents() {
+	threshold := time.Now().Add(-n.dedupeWindow)
+	removed, err := n.store.PruneProcessed(threshold)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to clean up processed events")
+		return
 	}
-	log.Debug().Msg("cleaned up processed events")
+	log.Debug().Int("removed", removed).Msg("cleaned up processed events")
 }
 
 func (n *Notifier) startCleanupRoutine() {
 	go func() {
-		ticker := time.NewTicker(eventThreshold)
+		ticker := time.NewTicker(cleanupInterval)
 		defer ticker.Stop()
-		for range ticker.C {
-			n.cleanupProcessedEvents()
+		for {
+			select {
+			case <-n.ctx.Done():
+				return
+			case <-ticker.C:
+				n.cleanupProcessedEvents()
+				n.prunePosted()
+			}
 		}
 	}()
 }
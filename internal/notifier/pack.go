@@ -0,0 +1,230 @@
+package notifier
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+const (
+	defaultPackWindow  = 1 * time.Minute
+	defaultPackMinSize = 3
+
+	// packSeparators split emoji names into the tokens packs share
+	packSeparators = "_-"
+	// minPackToken keeps one-letter prefixes and suffixes from forming packs
+	minPackToken = 2
+)
+
+// WithPacks holds additions whose names have a pattern a pack could share,
+// like blob_*, *-parrot or catjam1..9, until no addition sharing it has
+// arrived for window. Families of at least minSize are announced together,
+// and smaller ones are announced like any other addition. Additions that
+// matched different rules aren't packed together. A zero window disables
+// packs.
+func WithPacks(window time.Duration, minSize int) Option {
+	return func(n *Notifier) {
+		if window >= 0 {
+			n.packs.window = window
+		}
+		if minSize > 1 {
+			n.packs.minSize = minSize
+		}
+	}
+}
+
+// packBuffer holds additions that may belong to a pack
+type packBuffer struct {
+	window  time.Duration
+	minSize int
+
+	mu     sync.Mutex
+	groups []*packGroup
+}
+
+// packGroup is a possible pack of additions that matched the same rule, if
+// any. keys narrows to the patterns every member shares.
+type packGroup struct {
+	rule    *Rule
+	keys    []string
+	members []*store.Emoji
+	timer   *time.Timer
+}
+
+// packKeys returns the naming patterns an emoji name could share with a pack:
+// the token before its first separator, the token after its last one, and the
+// name without a trailing number
+func packKeys(name string) []string {
+	var keys []string
+	if i := strings.IndexAny(name, packSeparators); i >= minPackToken {
+		keys = append(keys, "prefix:"+name[:i+1])
+	}
+	if i := strings.LastIndexAny(name, packSeparators); i >= 0 && len(name)-i-1 >= minPackToken {
+		keys = append(keys, "suffix:"+name[i:])
+	}
+	if base := strings.TrimRightFunc(name, unicode.IsDigit); base != name && len(base) >= minPackToken {
+		keys = append(keys, "series:"+base)
+	}
+	return keys
+}
+
+// holdForPack holds an addition whose name has a pack pattern, in the held
+// group of additions that matched the same rule it shares the most patterns
+// with, or in a group of its own, and reports whether it was held
+func (n *Notifier) holdForPack(emoji *store.Emoji, rule *Rule) bool {
+	p := &n.packs
+	if p.window <= 0 {
+		return false
+	}
+	keys := packKeys(emoji.Name)
+	if len(keys) == 0 {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n.markHeld(emoji)
+
+	// the group sharing the most patterns is the closest family
+	var best *packGroup
+	var bestShared []string
+	for _, g := range p.groups {
+		if g.rule != rule {
+			continue
+		}
+		if shared := sharedKeys(g.keys, keys); len(shared) > len(bestShared) {
+			best, bestShared = g, shared
+		}
+	}
+	if best == nil {
+		g := &packGroup{rule: rule, keys: keys, members: []*store.Emoji{emoji}}
+		g.timer = time.AfterFunc(p.window, func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if p.remove(g) {
+				n.startPackFlush(g)
+			}
+		})
+		p.groups = append(p.groups, g)
+		log.Debug().Str("emoji", emoji.Name).Strs("patterns", keys).Msg("holding emoji for a possible pack")
+		return true
+	}
+
+	best.keys = bestShared
+	best.members = append(best.members, emoji)
+	log.Debug().Str("emoji", emoji.Name).Strs("patterns", bestShared).Int("members", len(best.members)).Msg("holding emoji for pack")
+	if len(best.members) >= maxDigestSize {
+		p.remove(best)
+		n.startPackFlush(best)
+	} else {
+		best.timer.Reset(p.window)
+	}
+	return true
+}
+
+// sharedKeys returns the pack patterns of a that b has too
+func sharedKeys(a, b []string) []string {
+	return slices.DeleteFunc(slices.Clone(a), func(k string) bool { return !slices.Contains(b, k) })
+}
+
+// remove forgets a group and reports whether it was still held. The caller
+// must hold p.mu.
+func (p *packBuffer) remove(g *packGroup) bool {
+	i := slices.Index(p.groups, g)
+	if i < 0 {
+		return false
+	}
+	g.timer.Stop()
+	p.groups = slices.Delete(p.groups, i, i+1)
+	return true
+}
+
+// startPackFlush announces a group in the background. The caller must hold
+// n.packs.mu so drainHeld can't miss a flush that is just starting.
+func (n *Notifier) startPackFlush(g *packGroup) {
+	key, members, rule := g.keys[0], g.members, g.rule
+	n.inBackground(func() { n.flushPack(key, members, rule) })
+}
+
+// flushPack announces a group as a pack, or hands its members on one by one
+// if too few of them turned up, as they may still be part of a burst. The
+// members matched rule, if it isn't nil.
+func (n *Notifier) flushPack(key string, members []*store.Emoji, rule *Rule) {
+	if len(members) < n.packs.minSize {
+		for _, emoji := range members {
+			if n.bufferBurst(emoji, rule) {
+				continue
+			}
+			n.deliver(n.ctx, &delivery{kind: announceAdd, emoji: emoji, rule: rule})
+			n.unmarkHeld([]*store.Emoji{emoji})
+		}
+		return
+	}
+
+	defer n.unmarkHeld(members)
+	d := packDelivery(key, members, rule)
+	log.Info().Str("pack", d.pack).Int("emojis", len(d.batch)).Msg("announcing emoji pack")
+	n.deliver(n.ctx, d)
+}
+
+// packDelivery is the announcement of a pack of members
+func packDelivery(key string, members []*store.Emoji, rule *Rule) *delivery {
+	// members arrive from several workers, so list them in a stable order
+	slices.SortFunc(members, func(a, b *store.Emoji) int { return strings.Compare(a.Name, b.Name) })
+	label := packLabel(key, emojiNames(members))
+	return &delivery{kind: announcePack, emoji: members[0], batch: members, pack: label, rule: rule}
+}
+
+// drainPacks starts announcing every held group
+func (n *Notifier) drainPacks() {
+	p := &n.packs
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, g := range slices.Clone(p.groups) {
+		p.remove(g)
+		n.startPackFlush(g)
+	}
+}
+
+// packLabel describes the pattern a pack shares, like blob_*, *-parrot or catjam1..9
+func packLabel(key string, names []string) string {
+	kind, pattern, _ := strings.Cut(key, ":")
+	switch kind {
+	case "prefix":
+		return pattern + "*"
+	case "suffix":
+		return "*" + pattern
+	}
+
+	var numbers []int
+	for _, name := range names {
+		if n, err := strconv.Atoi(strings.TrimPrefix(name, pattern)); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	if len(numbers) == 0 {
+		return pattern + "*"
+	}
+	return fmt.Sprintf("%s%d..%d", pattern, slices.Min(numbers), slices.Max(numbers))
+}
+
+// buildPackAnnouncement builds the Slack message announcing a pack with all its members
+func buildPackAnnouncement(t *Templates, label string, batch []*store.Emoji, sentence string) slack.MessageContent {
+	data := emojiTemplateData(batch[0], time.Now())
+	data.Pack = label
+	data.Names = emojiNames(batch)
+	data.Count = len(batch)
+	data.Sentence = sentence
+	return t.render(TemplatePack, data)
+}
@@ -0,0 +1,174 @@
+package notifier
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// TestPackKeys checks which naming patterns a name could share with a pack
+func TestPackKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{name: "cat"},
+		{name: "blob_wave", want: []string{"prefix:blob_", "suffix:_wave"}},
+		{name: "party-parrot", want: []string{"prefix:party-", "suffix:-parrot"}},
+		{name: "a_big_cat", want: []string{"suffix:_cat"}},
+		{name: "blob_x", want: []string{"prefix:blob_"}},
+		{name: "catjam3", want: []string{"series:catjam"}},
+		{name: "blob_cat2", want: []string{"prefix:blob_", "suffix:_cat2", "series:blob_cat"}},
+		{name: "x9"},
+		{name: "2024"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := packKeys(tt.name); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("packKeys(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPackLabel checks how a pack's shared pattern is described
+func TestPackLabel(t *testing.T) {
+	tests := []struct {
+		key   string
+		names []string
+		want  string
+	}{
+		{key: "prefix:blob_", names: []string{"blob_wave", "blob_cry"}, want: "blob_*"},
+		{key: "suffix:-parrot", names: []string{"party-parrot", "sad-parrot"}, want: "*-parrot"},
+		{key: "series:catjam", names: []string{"catjam3", "catjam1", "catjam10"}, want: "catjam1..10"},
+		{key: "series:catjam", names: []string{"catjam2", "catjam2"}, want: "catjam2..2"},
+		{key: "series:catjam", names: []string{"catjam_x"}, want: "catjam*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := packLabel(tt.key, tt.names); got != tt.want {
+				t.Errorf("packLabel(%q, %v) = %q, want %q", tt.key, tt.names, got, tt.want)
+			}
+		})
+	}
+}
+
+// newPackNotifier returns a notifier holding additions for packs within an
+// hour, with its held groups' timers stopped when the test ends
+func newPackNotifier(t *testing.T) *Notifier {
+	t.Helper()
+	st, err := store.Open("memory", "")
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	n := &Notifier{store: st, templates: builtinTemplates}
+	n.packs.window = time.Hour
+	n.packs.minSize = defaultPackMinSize
+	t.Cleanup(func() {
+		for _, g := range n.packs.groups {
+			g.timer.Stop()
+		}
+	})
+	return n
+}
+
+// groupNames lists the members of every held group, by the rule they matched
+func groupNames(n *Notifier) map[*Rule][][]string {
+	groups := make(map[*Rule][][]string)
+	for _, g := range n.packs.groups {
+		groups[g.rule] = append(groups[g.rule], emojiNames(g.members))
+	}
+	return groups
+}
+
+// TestHoldForPack checks that every addition with a pack pattern is held, in
+// the group of additions that matched the same rule it shares the most
+// patterns with
+func TestHoldForPack(t *testing.T) {
+	team := &Rule{Name: "team"}
+	tests := []struct {
+		name  string
+		steps []string
+		rules map[string]*Rule
+		want  map[*Rule][][]string
+	}{
+		{
+			name: "closest family",
+			// cat_jam3 shares _jam3 with the first group, but cat_ and cat_jam with the second
+			steps: []string{"blob_jam3", "cat", "dog_jam3", "cat_jam1", "party-parrot", "cat_jam2", "cat_jam3"},
+			want: map[*Rule][][]string{nil: {
+				{"blob_jam3", "dog_jam3"},
+				{"cat_jam1", "cat_jam2", "cat_jam3"},
+				{"party-parrot"},
+			}},
+		},
+		{
+			name:  "separate rules",
+			steps: []string{"blob_wave", "blob_cry", "blob_dance", "blob_nod"},
+			rules: map[string]*Rule{"blob_cry": team, "blob_dance": team},
+			want: map[*Rule][][]string{
+				nil:  {{"blob_wave", "blob_nod"}},
+				team: {{"blob_cry", "blob_dance"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newPackNotifier(t)
+			for _, name := range tt.steps {
+				want := len(packKeys(name)) > 0
+				if held := n.holdForPack(&store.Emoji{Name: name}, tt.rules[name]); held != want {
+					t.Errorf("holdForPack(%q) = %v, want %v", name, held, want)
+				}
+			}
+			if got := groupNames(n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("groups = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPackAnnouncement checks that a pack lists every member it counts and
+// labels, in name order
+func TestPackAnnouncement(t *testing.T) {
+	n := newPackNotifier(t)
+	for _, name := range []string{"cat_jam3", "cat_jam1", "cat_jam2"} {
+		n.holdForPack(&store.Emoji{Name: name}, nil)
+	}
+	if len(n.packs.groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(n.packs.groups))
+	}
+	g := n.packs.groups[0]
+
+	d := packDelivery(g.keys[0], g.members, g.rule)
+	d.sentence = "Three cats, one groove."
+	content, err := n.compose(context.Background(), d)
+	if err != nil {
+		t.Fatalf("composing: %v", err)
+	}
+
+	if want := "New emoji pack: cat_* (3 emojis)"; content.Fallback() != want {
+		t.Errorf("fallback = %q, want %q", content.Fallback(), want)
+	}
+	last := -1
+	for _, name := range []string{"cat_jam1", "cat_jam2", "cat_jam3"} {
+		i := strings.Index(content.Body, ":"+name+":")
+		if i < 0 {
+			t.Errorf("body doesn't list %s:\n%s", name, content.Body)
+			continue
+		}
+		if i < last {
+			t.Errorf("body lists %s out of order:\n%s", name, content.Body)
+		}
+		last = i
+	}
+}
@@ -0,0 +1,274 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultWorkers     = 4
+	defaultQueueSize   = 100
+	queueReportEvery   = 1 * time.Minute
+	defaultQueuePolicy = PolicyBlock
+)
+
+// BackpressurePolicy decides what happens when a worker's queue is full
+type BackpressurePolicy string
+
+const (
+	// PolicyBlock waits for room in the queue
+	PolicyBlock BackpressurePolicy = "block"
+	// PolicyDropNewest drops the job that didn't fit
+	PolicyDropNewest BackpressurePolicy = "drop-newest"
+	// PolicyDropOldest drops the longest waiting job to make room
+	PolicyDropOldest BackpressurePolicy = "drop-oldest"
+)
+
+// ParseBackpressurePolicy validates a backpressure policy name
+func ParseBackpressurePolicy(value string) (BackpressurePolicy, error) {
+	switch policy := BackpressurePolicy(value); policy {
+	case PolicyBlock, PolicyDropNewest, PolicyDropOldest:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unsupported queue policy %q", value)
+	}
+}
+
+type jobKind string
+
+const (
+	jobAdd    jobKind = "add"
+	jobRemove jobKind = "remove"
+	jobRename jobKind = "rename"
+)
+
+// job is a unit of emoji work. Jobs for the same emoji always run on the same
+// worker, in the order they were queued. Renames are keyed by the new name,
+// which later events refer to, so they wait for the rename to finish.
+type job struct {
+	kind    jobKind
+	name    string
+	value   string
+	oldName string
+}
+
+// QueueStats reports the current state of the work queue
+type QueueStats struct {
+	Depth     int
+	Processed int64
+	Dropped   int64
+}
+
+// workQueue runs jobs on a fixed set of workers, sharded by emoji name. The
+// shards are only closed once no push is under way, so a push never sends on
+// a closed shard, and pushes waiting for room give up when done is closed.
+// Every job that is refused, dropped or given up on is handed to lost.
+type workQueue struct {
+	shards    []chan job
+	mu        sync.RWMutex
+	closed    bool
+	done      chan struct{}
+	pushing   sync.WaitGroup
+	policy    BackpressurePolicy
+	handle    func(job)
+	lost      func(job)
+	wg        sync.WaitGroup
+	processed atomic.Int64
+	dropped   atomic.Int64
+}
+
+func newWorkQueue(workers, size int, policy BackpressurePolicy, handle, lost func(job)) *workQueue {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+
+	perShard := size / workers
+	if perShard < 1 {
+		perShard = 1
+	}
+
+	q := &workQueue{
+		shards: make([]chan job, workers),
+		done:   make(chan struct{}),
+		policy: policy,
+		handle: handle,
+		lost:   lost,
+	}
+	for i := range q.shards {
+		q.shards[i] = make(chan job, perShard)
+	}
+	return q
+}
+
+// start launches one worker per shard
+func (q *workQueue) start() {
+	for i, shard := range q.shards {
+		q.wg.Add(1)
+		go func(id int, jobs <-chan job) {
+			defer q.wg.Done()
+			for j := range jobs {
+				log.Debug().Int("worker", id).Str("kind", string(j.kind)).Str("emoji", j.name).Msg("worker picked up job")
+				q.handle(j)
+				q.processed.Add(1)
+			}
+		}(i, shard)
+	}
+}
+
+// shardFor picks the worker that owns an emoji name
+func (q *workQueue) shardFor(name string) chan job {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return q.shards[h.Sum32()%uint32(len(q.shards))]
+}
+
+// push queues a job according to the backpressure policy and reports whether
+// it was accepted. Jobs are refused once the queue is closed, and a blocked
+// push gives up when ctx is done or the queue is closed.
+func (q *workQueue) push(ctx context.Context, j job) bool {
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		log.Warn().Str("kind", string(j.kind)).Str("emoji", j.name).Msg("work queue closed, refusing job")
+		q.lose(j)
+		return false
+	}
+	q.pushing.Add(1)
+	q.mu.RUnlock()
+	defer q.pushing.Done()
+
+	shard := q.shardFor(j.name)
+
+	select {
+	case shard <- j:
+		return true
+	default:
+	}
+
+	switch q.policy {
+	case PolicyDropNewest:
+		q.drop(j, "queue full, dropping newest job")
+		return false
+
+	case PolicyDropOldest:
+		for {
+			select {
+			case shard <- j:
+				return true
+			default:
+			}
+			select {
+			case oldest := <-shard:
+				q.drop(oldest, "queue full, dropping oldest job")
+			default:
+			}
+		}
+
+	default:
+		log.Warn().Str("emoji", j.name).Msg("work queue full, waiting for room")
+		select {
+		case shard <- j:
+			return true
+		case <-ctx.Done():
+			log.Warn().Str("kind", string(j.kind)).Str("emoji", j.name).Msg("gave up waiting for room in work queue")
+			q.lose(j)
+			return false
+		case <-q.done:
+			log.Warn().Str("kind", string(j.kind)).Str("emoji", j.name).Msg("work queue closed while waiting for room")
+			q.lose(j)
+			return false
+		}
+	}
+}
+
+// close stops accepting jobs and refuses the pushes still waiting for room.
+// Workers exit once they have taken every job already queued.
+func (q *workQueue) close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.done)
+	q.mu.Unlock()
+
+	q.pushing.Wait()
+	for _, shard := range q.shards {
+		close(shard)
+	}
+}
+
+// wait returns a channel that is closed once every worker has exited
+func (q *workQueue) wait() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+func (q *workQueue) drop(j job, reason string) {
+	q.dropped.Add(1)
+	log.Warn().Str("kind", string(j.kind)).Str("emoji", j.name).Str("policy", string(q.policy)).Msg(reason)
+	q.lose(j)
+}
+
+func (q *workQueue) lose(j job) {
+	if q.lost != nil {
+		q.lost(j)
+	}
+}
+
+// stats returns the current depth and counters of the queue
+func (q *workQueue) stats() QueueStats {
+	depth := 0
+	for _, shard := range q.shards {
+		depth += len(shard)
+	}
+	return QueueStats{
+		Depth:     depth,
+		Processed: q.processed.Load(),
+		Dropped:   q.dropped.Load(),
+	}
+}
+
+// startReporter logs the queue stats periodically until ctx is done, as a
+// warning when jobs were dropped
+func (q *workQueue) startReporter(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(queueReportEvery)
+		defer ticker.Stop()
+
+		var lastDropped int64
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			stats := q.stats()
+			event := log.Debug()
+			if stats.Dropped > lastDropped {
+				event = log.Warn()
+			}
+			event.
+				Int("depth", stats.Depth).
+				Int64("processed", stats.Processed).
+				Int64("dropped", stats.Dropped).
+				Msg("work queue stats")
+			lastDropped = stats.Dropped
+		}
+	}()
+}
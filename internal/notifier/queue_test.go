@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestQueueCloseWhileBlocked checks that closing a full queue refuses a push
+// waiting for room instead of hanging on it
+func TestQueueCloseWhileBlocked(t *testing.T) {
+	release := make(chan struct{})
+	q := newWorkQueue(1, 1, PolicyBlock, func(job) { <-release }, nil)
+	q.start()
+
+	// one job keeps the worker busy and the next fills the shard
+	q.push(context.Background(), job{kind: jobAdd, name: "a"})
+	for len(q.shards[0]) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	q.push(context.Background(), job{kind: jobAdd, name: "b"})
+
+	pushed := make(chan bool)
+	go func() { pushed <- q.push(context.Background(), job{kind: jobAdd, name: "c"}) }()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		q.close()
+		close(closed)
+	}()
+
+	select {
+	case ok := <-pushed:
+		if ok {
+			t.Error("push waiting for room was accepted after the queue closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("push waiting for room didn't give up when the queue closed")
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("closing the queue hung")
+	}
+
+	close(release)
+	select {
+	case <-q.wait():
+	case <-time.After(time.Second):
+		t.Fatal("workers didn't exit")
+	}
+	if got := q.stats().Processed; got != 2 {
+		t.Errorf("processed %d jobs, want the 2 queued before closing", got)
+	}
+}
+
+// TestQueueLosesDroppedJobs checks that every job a full queue doesn't run is
+// handed to its lost callback
+func TestQueueLosesDroppedJobs(t *testing.T) {
+	tests := []struct {
+		policy BackpressurePolicy
+		want   string
+	}{
+		{PolicyDropNewest, "b"},
+		{PolicyDropOldest, "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			var lost []string
+			// workers aren't started, so the single slot stays full
+			q := newWorkQueue(1, 1, tt.policy, func(job) {}, func(j job) { lost = append(lost, j.name) })
+
+			q.push(context.Background(), job{kind: jobAdd, name: "a"})
+			q.push(context.Background(), job{kind: jobAdd, name: "b"})
+
+			if len(lost) != 1 || lost[0] != tt.want {
+				t.Errorf("lost %v, want [%s]", lost, tt.want)
+			}
+			if got := q.stats().Dropped; got != 1 {
+				t.Errorf("dropped %d jobs, want 1", got)
+			}
+		})
+	}
+}
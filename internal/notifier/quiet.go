@@ -0,0 +1,271 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// heldCheckInterval is how often held announcements are checked for being due
+const heldCheckInterval = 30 * time.Second
+
+// QuietHours is a daily period in which a channel gets no announcements. Start
+// and End are times of day in Location; a Start after End spans midnight.
+type QuietHours struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// ParseQuietHours parses a comma-separated list of [channel=]HH:MM-HH:MM[@zone]
+// entries, like "22:00-08:00@Europe/Berlin,#emoji-graveyard=18:00-09:00".
+// Entries without a channel apply to the default channel, and entries without
+// a zone use the local time zone.
+func ParseQuietHours(value string) (map[string]QuietHours, error) {
+	hours := make(map[string]QuietHours)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		channel, period, ok := strings.Cut(entry, "=")
+		if !ok {
+			channel, period = "", entry
+		}
+		period, zone, _ := strings.Cut(period, "@")
+		start, end, ok := strings.Cut(period, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid quiet hours %q: expected HH:MM-HH:MM", entry)
+		}
+
+		q := QuietHours{Location: time.Local}
+		var err error
+		if q.Start, err = parseTimeOfDay(start); err != nil {
+			return nil, fmt.Errorf("invalid quiet hours %q: %w", entry, err)
+		}
+		if q.End, err = parseTimeOfDay(end); err != nil {
+			return nil, fmt.Errorf("invalid quiet hours %q: %w", entry, err)
+		}
+		if q.Start == q.End {
+			return nil, fmt.Errorf("invalid quiet hours %q: start and end are the same", entry)
+		}
+		if zone != "" {
+			if q.Location, err = time.LoadLocation(zone); err != nil {
+				return nil, fmt.Errorf("invalid quiet hours %q: %w", entry, err)
+			}
+		}
+
+		channel = strings.TrimSpace(channel)
+		if _, ok := hours[channel]; ok {
+			return nil, fmt.Errorf("quiet hours for channel %q are set more than once", channel)
+		}
+		hours[channel] = q
+	}
+	return hours, nil
+}
+
+// CheckQuietHoursChannels makes sure quiet hours are only set for channels
+// announcements are posted to, named exactly as the destinations or the
+// removal channel name them, since others would never apply
+func CheckQuietHoursChannels(hours map[string]QuietHours, destinations []*Destination, removalChannel string) error {
+	var channels []string
+	if removalChannel != "" {
+		channels = append(channels, removalChannel)
+	}
+	if len(destinations) == 0 {
+		channels = append(channels, "")
+	}
+	for _, dest := range destinations {
+		channels = append(channels, dest.Channel)
+	}
+
+	for channel := range hours {
+		if channel == "" && !slices.Contains(channels, "") {
+			return errors.New("quiet hours are set for the default channel, but no destination posts there")
+		}
+		if !slices.Contains(channels, channel) {
+			known := slices.DeleteFunc(slices.Clone(channels), func(c string) bool { return c == "" })
+			return fmt.Errorf("quiet hours are set for channel %q, which isn't a destination or the removal channel: expected one of %q", channel, slices.Compact(slices.Sorted(slices.Values(known))))
+		}
+	}
+	return nil
+}
+
+// parseTimeOfDay parses HH:MM into the time since midnight
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// until returns when the quiet hours covering t end, and whether any do
+func (q QuietHours) until(t time.Time) (time.Time, bool) {
+	t = t.In(q.Location)
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	endOn := func(days int) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, q.Location).Add(q.End)
+	}
+
+	switch {
+	case q.Start < q.End && clock >= q.Start && clock < q.End:
+		return endOn(0), true
+	case q.Start > q.End && clock >= q.Start:
+		return endOn(1), true
+	case q.Start > q.End && clock < q.End:
+		return endOn(0), true
+	}
+	return time.Time{}, false
+}
+
+// WithQuietHours holds announcements for a channel during its quiet hours and
+// delivers them, in the order they were held, once the quiet hours end. The
+// default channel is keyed by an empty name.
+func WithQuietHours(hours map[string]QuietHours) Option {
+	return func(n *Notifier) {
+		n.quietHours = hours
+	}
+}
+
+// channelOf returns the channel a delivery is posted to, empty for the default channel
+func (n *Notifier) channelOf(d *delivery) string {
+	if d.kind == announceRemove && n.removalChannel != "" {
+		return n.removalChannel
+	}
+	if d.dest != nil {
+		return d.dest.Channel
+	}
+	return ""
+}
+
+// holdForQuietHours holds a delivery if its channel is in quiet hours, or
+// still has held announcements waiting to go out ahead of it, and reports
+// whether it was held. The sentence is generated right away so the message is
+// ready when it is released.
+func (n *Notifier) holdForQuietHours(ctx context.Context, d *delivery) bool {
+	channel := n.channelOf(d)
+	q, ok := n.quietHours[channel]
+	if !ok {
+		return false
+	}
+
+	now := time.Now()
+	due, quiet := q.until(now)
+	if quiet {
+		if _, err := n.buildMessage(ctx, d); err != nil {
+			log.Warn().Err(err).Str("emoji", d.emoji.Name).Msg("failed to prepare held announcement, trying again when it is released")
+		}
+	}
+
+	n.heldMu.Lock()
+	defer n.heldMu.Unlock()
+
+	if !quiet {
+		if !n.hasHeld(channel) {
+			return false
+		}
+		due = now
+	}
+
+	h := &store.HeldAnnouncement{OutboxEntry: store.OutboxEntry{ID: d.id(), CreatedAt: now, UpdatedAt: now}, Channel: channel, Due: due}
+	d.describe(&h.OutboxEntry)
+	if err := n.store.PutHeld(h); err != nil {
+		log.Error().Err(err).Str("emoji", d.emoji.Name).Msg("failed to hold announcement, sending it now")
+		return false
+	}
+	log.Info().Str("emoji", d.emoji.Name).Str("kind", d.kind).Str("channel", channel).Time("due", due).Msg("holding announcement for quiet hours")
+
+	if !quiet {
+		// the backlog is already due, so don't wait for the next check
+		select {
+		case n.heldWake <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// hasHeld reports whether announcements for a channel are waiting to be
+// released. The caller must hold n.heldMu.
+func (n *Notifier) hasHeld(channel string) bool {
+	held, err := n.store.ListHeld()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list held announcements")
+		return false
+	}
+	for _, h := range held {
+		if h.Channel == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// StartQuietHours delivers held announcements once they are due until ctx is
+// done. Announcements held before a restart are delivered too.
+func (n *Notifier) StartQuietHours(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(heldCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			n.releaseHeld()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-n.heldWake:
+			}
+		}
+	}()
+}
+
+// releaseHeld delivers every due held announcement in the order they were
+// held. A channel's announcements stop at the first one that isn't due yet.
+func (n *Notifier) releaseHeld() {
+	n.heldMu.Lock()
+	held, err := n.store.ListHeld()
+	n.heldMu.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list held announcements")
+		return
+	}
+
+	now := time.Now()
+	blocked := make(map[string]bool)
+	for _, h := range held {
+		if n.shuttingDown() {
+			return
+		}
+		if blocked[h.Channel] || h.Due.After(now) {
+			blocked[h.Channel] = true
+			continue
+		}
+
+		d, err := n.restore(&h.OutboxEntry)
+		if err != nil {
+			log.Error().Err(err).Str("emoji", h.Emoji).Msg("failed to load held announcement")
+			blocked[h.Channel] = true
+			continue
+		}
+		log.Info().Str("emoji", d.emoji.Name).Str("kind", d.kind).Str("channel", h.Channel).Msg("delivering held announcement")
+		// failures go to the outbox like any other announcement
+		n.send(n.ctx, d)
+
+		n.heldMu.Lock()
+		if err := n.store.DeleteHeld(h.Key); err != nil {
+			log.Error().Err(err).Str("emoji", h.Emoji).Msg("failed to forget held announcement")
+		}
+		n.heldMu.Unlock()
+	}
+}
@@ -0,0 +1,140 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseQuietHours checks the accepted entries and the errors for bad ones
+func TestParseQuietHours(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		value   string
+		want    map[string]QuietHours
+		wantErr bool
+	}{
+		{value: "", want: map[string]QuietHours{}},
+		{
+			value: "22:00-08:00",
+			want:  map[string]QuietHours{"": {Start: 22 * time.Hour, End: 8 * time.Hour, Location: time.Local}},
+		},
+		{
+			value: " 22:00-08:00@Europe/Berlin , #emoji-graveyard = 18:30-09:00 ",
+			want: map[string]QuietHours{
+				"":                 {Start: 22 * time.Hour, End: 8 * time.Hour, Location: berlin},
+				"#emoji-graveyard": {Start: 18*time.Hour + 30*time.Minute, End: 9 * time.Hour, Location: time.Local},
+			},
+		},
+		{value: "22:00", wantErr: true},
+		{value: "25:00-08:00", wantErr: true},
+		{value: "08:00-08:00", wantErr: true},
+		{value: "22:00-08:00@Nowhere/Special", wantErr: true},
+		{value: "22:00-08:00,23:00-07:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseQuietHours(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseQuietHours(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuietHours(%q): %v", tt.value, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseQuietHours(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+			for channel, want := range tt.want {
+				q := got[channel]
+				if q.Start != want.Start || q.End != want.End || q.Location.String() != want.Location.String() {
+					t.Errorf("quiet hours for %q = %v, want %v", channel, q, want)
+				}
+			}
+		})
+	}
+}
+
+// TestQuietHoursUntil checks when quiet hours end, in particular across midnight
+func TestQuietHoursUntil(t *testing.T) {
+	day := func(d, h, m int) time.Time { return time.Date(2026, time.March, d, h, m, 0, 0, time.UTC) }
+	overnight := QuietHours{Start: 22 * time.Hour, End: 8 * time.Hour, Location: time.UTC}
+	daytime := QuietHours{Start: 9 * time.Hour, End: 17 * time.Hour, Location: time.UTC}
+
+	tests := []struct {
+		name  string
+		q     QuietHours
+		at    time.Time
+		want  time.Time
+		quiet bool
+	}{
+		{name: "overnight before start", q: overnight, at: day(10, 21, 59)},
+		{name: "overnight at start", q: overnight, at: day(10, 22, 0), want: day(11, 8, 0), quiet: true},
+		{name: "overnight before midnight", q: overnight, at: day(10, 23, 30), want: day(11, 8, 0), quiet: true},
+		{name: "overnight after midnight", q: overnight, at: day(11, 3, 0), want: day(11, 8, 0), quiet: true},
+		{name: "overnight at end", q: overnight, at: day(11, 8, 0)},
+		{name: "overnight across a month", q: overnight, at: day(31, 23, 0), want: time.Date(2026, time.April, 1, 8, 0, 0, 0, time.UTC), quiet: true},
+		{name: "daytime inside", q: daytime, at: day(10, 12, 0), want: day(10, 17, 0), quiet: true},
+		{name: "daytime before", q: daytime, at: day(10, 8, 59)},
+		{name: "daytime after", q: daytime, at: day(10, 17, 0)},
+		{
+			name:  "other time zone",
+			q:     QuietHours{Start: 22 * time.Hour, End: 8 * time.Hour, Location: time.FixedZone("UTC+2", 2*60*60)},
+			at:    day(10, 21, 0),
+			want:  day(11, 6, 0),
+			quiet: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, quiet := tt.q.until(tt.at)
+			if quiet != tt.quiet || !got.Equal(tt.want) {
+				t.Errorf("until(%v) = %v, %v, want %v, %v", tt.at, got, quiet, tt.want, tt.quiet)
+			}
+		})
+	}
+}
+
+// TestCheckQuietHoursChannels checks that quiet hours must name a channel
+// announcements are posted to
+func TestCheckQuietHoursChannels(t *testing.T) {
+	design := &Destination{Name: "design", Channel: "#design-emoji"}
+	defaults := &Destination{Name: "main"}
+
+	tests := []struct {
+		name         string
+		channels     []string
+		destinations []*Destination
+		removal      string
+		wantErr      bool
+	}{
+		{name: "default channel", channels: []string{""}},
+		{name: "removal channel", channels: []string{"#graveyard"}, removal: "#graveyard"},
+		{name: "removal channel without its hash", channels: []string{"graveyard"}, removal: "#graveyard", wantErr: true},
+		{name: "unset removal channel", channels: []string{"#graveyard"}, wantErr: true},
+		{name: "destination channel", channels: []string{"#design-emoji"}, destinations: []*Destination{design}},
+		{name: "destination name", channels: []string{"design"}, destinations: []*Destination{design}, wantErr: true},
+		{name: "default channel without a destination there", channels: []string{""}, destinations: []*Destination{design}, wantErr: true},
+		{name: "default channel with a destination there", channels: []string{""}, destinations: []*Destination{design, defaults}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hours := make(map[string]QuietHours)
+			for _, channel := range tt.channels {
+				hours[channel] = QuietHours{Start: 22 * time.Hour, End: 8 * time.Hour, Location: time.UTC}
+			}
+			err := CheckQuietHoursChannels(hours, tt.destinations, tt.removal)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckQuietHoursChannels() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// Reconcile diffs the workspace emoji catalog against the known emojis and
+// queues any additions, image changes or removals that were missed. The first run only
+// seeds the known emojis so existing emojis aren't announced.
+func (n *Notifier) Reconcile(ctx context.Context) error {
+	current, err := n.slackClient.ListEmojis(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list emojis: %w", err)
+	}
+
+	baselineAt, err := n.store.BaselineAt()
+	if err != nil {
+		return fmt.Errorf("failed to load baseline: %w", err)
+	}
+	if baselineAt.IsZero() {
+		return n.seedKnownEmojis(current)
+	}
+
+	known, err := n.store.ListEmojis()
+	if err != nil {
+		return fmt.Errorf("failed to load known emojis: %w", err)
+	}
+	active := make(map[string]bool, len(known))
+	urls := make(map[string]string, len(known))
+	for _, emoji := range known {
+		active[emoji.Name] = emoji.Active
+		urls[emoji.Name] = emoji.URL
+	}
+
+	added, changed, removed := 0, 0, 0
+	for name, value := range current {
+		if active[name] && !imageChanged(urls[name], value) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if active[name] {
+			// the add job decides whether the new value is announced
+			log.Info().Str("emoji", name).Msg("reconcile found changed emoji")
+			changed++
+		} else {
+			log.Info().Str("emoji", name).Msg("reconcile found missed emoji addition")
+			added++
+		}
+		n.push(ctx, job{kind: jobAdd, name: name, value: value})
+	}
+	for name, isActive := range active {
+		if _, ok := current[name]; ok || !isActive {
+			continue
+		}
+		log.Info().Str("emoji", name).Msg("reconcile found missed emoji removal")
+		n.push(ctx, job{kind: jobRemove, name: name})
+		removed++
+	}
+
+	log.Debug().Int("catalog", len(current)).Int("added", added).Int("changed", changed).Int("removed", removed).Msg("reconciled emoji catalog")
+	return nil
+}
+
+// imageChanged reports whether an emoji's catalog value now points at a
+// different image. Retargeted aliases aren't tracked.
+func imageChanged(known, current string) bool {
+	_, wasAlias := parseAlias(known)
+	_, isAlias := parseAlias(current)
+	return known != current && !wasAlias && !isAlias
+}
+
+// seedKnownEmojis records the current catalog as known without announcing anything
+func (n *Notifier) seedKnownEmojis(current map[string]string) error {
+	n.eventsMutex.Lock()
+	defer n.eventsMutex.Unlock()
+
+	now := time.Now()
+	for name, value := range current {
+		emoji, err := n.store.GetEmoji(name)
+		if errors.Is(err, store.ErrNotFound) {
+			emoji = &store.Emoji{Name: name, AddedAt: now}
+		} else if err != nil {
+			return fmt.Errorf("failed to load emoji %s: %w", name, err)
+		}
+
+		emoji.URL = value
+		emoji.AliasOf, _ = parseAlias(value)
+		emoji.Active = true
+		if err := n.store.PutEmoji(emoji); err != nil {
+			return fmt.Errorf("failed to save emoji %s: %w", name, err)
+		}
+	}
+
+	if err := n.store.SetBaselineAt(now); err != nil {
+		return fmt.Errorf("failed to save baseline: %w", err)
+	}
+	log.Info().Int("emojis", len(current)).Msg("seeded known emojis from workspace catalog")
+	return nil
+}
+
+// StartReconciler reconciles immediately and then on every interval until ctx is done
+func (n *Notifier) StartReconciler(ctx context.Context, interval time.Duration) {
+	go func() {
+		if err := n.Reconcile(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to reconcile emoji catalog")
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := n.Reconcile(ctx); err != nil {
+					log.Error().Err(err).Msg("failed to reconcile emoji catalog")
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,126 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// WithRemovalNotice sets whether and how emoji removals are announced, and the
+// channel removal notices go to. An empty channel uses the default channel.
+func WithRemovalNotice(mode NoticeMode, channel string) Option {
+	return func(n *Notifier) {
+		if mode != "" {
+			n.removalNotice = mode
+		}
+		n.removalChannel = channel
+	}
+}
+
+// removedNames returns every distinct emoji name in a remove event
+func removedNames(ev *slackevents.EmojiChangedEvent) []string {
+	var names []string
+	for _, name := range append([]string{ev.Name}, ev.Names...) {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (n *Notifier) handleRemovedEmoji(ctx context.Context, name string) {
+	emoji, ok := n.claimRemovedEmoji(name)
+	if !ok {
+		return
+	}
+
+	if n.logOnly {
+		log.Info().
+			Str("emoji", name).
+			Msg("logOnly mode: would have updated the announcement and sent a removal notice")
+		return
+	}
+
+	n.markAnnouncementRemoved(ctx, emoji)
+
+	if n.removalNotice == NoticeOff || (emoji.AliasOf != "" && n.aliasMode != AliasAnnounce) {
+		return
+	}
+	d := &delivery{kind: announceRemove, emoji: emoji}
+	if !n.applyRules(ctx, d, "remove") {
+		return
+	}
+	n.deliver(ctx, d)
+}
+
+// claimRemovedEmoji marks a known emoji as removed and reports whether it was active
+func (n *Notifier) claimRemovedEmoji(name string) (*store.Emoji, bool) {
+	n.eventsMutex.Lock()
+	defer n.eventsMutex.Unlock()
+
+	emoji, err := n.store.GetEmoji(name)
+	if errors.Is(err, store.ErrNotFound) {
+		log.Debug().Str("emoji", name).Msg("ignoring unaccounted emoji")
+		return nil, false
+	}
+	if err != nil {
+		log.Error().Err(err).Str("emoji", name).Msg("failed to load emoji state")
+		return nil, false
+	}
+	if !emoji.Active {
+		log.Debug().Str("emoji", name).Msg("ignoring already deleted emoji")
+		return nil, false
+	}
+
+	log.Info().Str("emoji", name).Msg("removing emoji from known emojis")
+	if emoji.AliasOf != "" {
+		n.unlinkAlias(name, emoji.AliasOf)
+	}
+	emoji.Active = false
+	emoji.RemovedAt = time.Now()
+	if err := n.store.PutEmoji(emoji); err != nil {
+		log.Error().Err(err).Str("emoji", name).Msg("failed to save emoji state")
+		return nil, false
+	}
+	n.record(store.HistoryEvent{At: emoji.RemovedAt, Type: store.HistoryRemoved, Emoji: name})
+	return emoji, true
+}
+
+// markAnnouncementRemoved updates the original announcements in place to show
+// the emoji is gone, dropping their now broken image
+func (n *Notifier) markAnnouncementRemoved(ctx context.Context, emoji *store.Emoji) {
+	for _, a := range announcements(emoji) {
+		ref := slack.MessageRef{Channel: a.Channel, Timestamp: a.Timestamp}
+		content := buildStruckAnnouncement(n.announcementTemplates(a), emoji, statusRemoved, a.Sentence, emoji.RemovedAt)
+
+		if err := n.slackClient.UpdateMessage(ctx, ref, content); err != nil {
+			log.Warn().Err(err).Str("emoji", emoji.Name).Str("ts", ref.Timestamp).Msg("failed to mark announcement as removed")
+			continue
+		}
+		log.Debug().Str("emoji", emoji.Name).Str("ts", ref.Timestamp).Msg("marked announcement as removed")
+	}
+}
+
+// buildStruckAnnouncement rewrites an announcement whose image is gone,
+// striking it through and stamping it with a status. It has no header or
+// image, since neither can be struck through.
+func buildStruckAnnouncement(t *Templates, emoji *store.Emoji, status, sentence string, at time.Time) slack.MessageContent {
+	data := emojiTemplateData(emoji, at)
+	data.Status = status
+	data.Sentence = sentence
+	return t.render(TemplateStruck, data)
+}
+
+// buildRemovalNotice builds the Slack message announcing a removed emoji
+func buildRemovalNotice(t *Templates, emoji *store.Emoji, sentence string) slack.MessageContent {
+	data := emojiTemplateData(emoji, emoji.RemovedAt)
+	data.Sentence = sentence
+	return t.render(TemplateRemove, data)
+}
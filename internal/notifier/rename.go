@@ -0,0 +1,93 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// WithRenameNotice sets whether and how emoji renames are announced
+func WithRenameNotice(mode NoticeMode) Option {
+	return func(n *Notifier) {
+		if mode != "" {
+			n.renameNotice = mode
+		}
+	}
+}
+
+func (n *Notifier) handleRenamedEmoji(ctx context.Context, oldName, newName, value string) {
+	emoji, ok := n.renameEmoji(oldName, newName, value)
+	if !ok || n.renameNotice == NoticeOff {
+		return
+	}
+	d := &delivery{kind: announceRename, emoji: emoji, previousName: oldName}
+	if !n.applyRules(ctx, d, "rename") {
+		return
+	}
+
+	if n.logOnly {
+		log.Info().
+			Str("old_name", oldName).
+			Str("emoji", newName).
+			Msg("logOnly mode: would have sent rename notice")
+		return
+	}
+
+	n.deliver(ctx, d)
+}
+
+// renameEmoji moves an emoji's state to its new name and reports whether the
+// rename should be announced. Unknown emojis are recorded under the new name
+// without a notice, since their addition was never announced either.
+func (n *Notifier) renameEmoji(oldName, newName, value string) (*store.Emoji, bool) {
+	n.eventsMutex.Lock()
+	defer n.eventsMutex.Unlock()
+
+	logger := log.With().Str("old_name", oldName).Str("emoji", newName).Logger()
+
+	emoji, err := n.store.RenameEmoji(oldName, newName)
+	if errors.Is(err, store.ErrNotFound) {
+		logger.Info().Msg("renamed emoji was unknown, recording it under its new name")
+		emoji = &store.Emoji{Name: newName, URL: value, Active: true}
+		emoji.AliasOf, _ = parseAlias(value)
+		if err := n.store.PutEmoji(emoji); err != nil {
+			logger.Error().Err(err).Msg("failed to save emoji state")
+		}
+		n.record(store.HistoryEvent{Type: store.HistoryRenamed, Emoji: newName, PreviousName: oldName, URL: value})
+		return nil, false
+	}
+	if errors.Is(err, store.ErrNameTaken) {
+		// the state missed a change to the new name; reconcile sorts out the old one
+		logger.Warn().Err(err).Msg("renamed emoji's new name is already known, leaving its state alone")
+		return nil, false
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to rename emoji state")
+		return nil, false
+	}
+
+	if value != "" && value != emoji.URL {
+		emoji.URL = value
+		emoji.AliasOf, _ = parseAlias(value)
+		if err := n.store.PutEmoji(emoji); err != nil {
+			logger.Error().Err(err).Msg("failed to save emoji state")
+		}
+	}
+
+	n.record(store.HistoryEvent{Type: store.HistoryRenamed, Emoji: newName, PreviousName: oldName, URL: value})
+	logger.Info().Msg("renamed emoji")
+	return emoji, emoji.Active
+}
+
+// buildRenameNotice builds the Slack message announcing a renamed emoji
+func buildRenameNotice(t *Templates, oldName string, emoji *store.Emoji, sentence string) slack.MessageContent {
+	data := emojiTemplateData(emoji, time.Now())
+	data.PreviousName = oldName
+	data.Sentence = sentence
+	return t.render(TemplateRename, data)
+}
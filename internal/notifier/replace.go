@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+const defaultReplaceWindow = 10 * time.Minute
+
+// WithReplaceWindow sets how soon after a removal a re-upload under the same
+// name counts as a new image rather than a return. Zero disables update
+// announcements.
+func WithReplaceWindow(window time.Duration) Option {
+	return func(n *Notifier) {
+		if window >= 0 {
+			n.replaceWindow = window
+		}
+	}
+}
+
+// readdedKind decides how to announce an add event for an emoji name that is
+// already known, or returns "" if it shouldn't be announced at all
+func (n *Notifier) readdedKind(emoji *store.Emoji, value string) string {
+	_, isAlias := parseAlias(value)
+	newImage := imageChanged(emoji.URL, value)
+
+	switch {
+	case emoji.Active && newImage && n.replaceWindow > 0:
+		// the removal was missed, e.g. while we were offline
+		return announceReplace
+	case emoji.Active:
+		return ""
+	case newImage && n.replaceWindow > 0 && time.Since(emoji.RemovedAt) <= n.replaceWindow:
+		return announceReplace
+	case isAlias:
+		return announceAdd
+	default:
+		return announceReturn
+	}
+}
+
+// markAnnouncementReplaced updates the original announcements in place to
+// show the emoji has a new image, dropping the old one
+func (n *Notifier) markAnnouncementReplaced(ctx context.Context, emoji *store.Emoji) {
+	for _, a := range announcements(emoji) {
+		ref := slack.MessageRef{Channel: a.Channel, Timestamp: a.Timestamp}
+		content := buildStruckAnnouncement(n.announcementTemplates(a), emoji, statusReplaced, a.Sentence, time.Now())
+
+		if err := n.slackClient.UpdateMessage(ctx, ref, content); err != nil {
+			log.Warn().Err(err).Str("emoji", emoji.Name).Str("ts", ref.Timestamp).Msg("failed to mark announcement as replaced")
+			continue
+		}
+		log.Debug().Str("emoji", emoji.Name).Str("ts", ref.Timestamp).Msg("marked announcement as replaced")
+	}
+}
+
+// earlierAnnouncement describes the first announcement of a returning emoji
+// in the delivery's destination, linking to it when Slack can provide a permalink
+func (n *Notifier) earlierAnnouncement(ctx context.Context, d *delivery) string {
+	earlier, ok := n.previousAnnouncement(d)
+	// once delivered, the announcement is its own previous one
+	if !ok || (earlier.Channel == d.ref.Channel && earlier.Timestamp == d.ref.Timestamp) {
+		return ""
+	}
+
+	date := earlier.AnnouncedAt.UTC().Format(time.DateOnly)
+	ref := slack.MessageRef{Channel: earlier.Channel, Timestamp: earlier.Timestamp}
+	link, err := n.slackClient.Permalink(ctx, ref)
+	if err != nil {
+		log.Warn().Err(err).Str("emoji", d.emoji.Name).Str("ts", ref.Timestamp).Msg("failed to get permalink of earlier announcement")
+		return fmt.Sprintf("Originally announced <!date^%d^{date_short}|%s>", earlier.AnnouncedAt.Unix(), date)
+	}
+	return fmt.Sprintf("<%s|Originally announced %s>", link, date)
+}
+
+// buildUpdateAnnouncement builds the Slack message showing an emoji's old and new images
+func buildUpdateAnnouncement(t *Templates, emoji *store.Emoji, previousURL, sentence string) slack.MessageContent {
+	data := emojiTemplateData(emoji, time.Now())
+	data.PreviousImageURL = previousURL
+	data.Sentence = sentence
+	content := t.render(TemplateReplace, data)
+	content.Images = replacedImages(emoji.Name, previousURL, emoji.URL)
+	return content
+}
+
+// replacedImages shows an emoji's old image above its new one
+func replacedImages(name, previousURL, url string) []slack.Image {
+	return append(emojiImage(name+" (before)", previousURL, "Before"), emojiImage(name, url, "After")...)
+}
+
+// buildWelcomeBackAnnouncement builds the Slack message for an emoji that was re-added after a removal
+func buildWelcomeBackAnnouncement(t *Templates, emoji *store.Emoji, sentence, earlier string) slack.MessageContent {
+	data := emojiTemplateData(emoji, emoji.AddedAt)
+	data.Sentence = sentence
+	data.Earlier = earlier
+	content := t.render(TemplateReturn, data)
+	content.Images = emojiImage(emoji.Name, emoji.URL, emoji.Name)
+	return content
+}
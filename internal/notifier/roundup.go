@@ -0,0 +1,273 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// roundupKind is what destinations filter roundups by
+const roundupKind = "roundup"
+
+// Roundup is every emoji change recorded during a period
+type Roundup struct {
+	From    time.Time
+	To      time.Time
+	Added   []string
+	Removed []string
+	Renamed [][2]string
+}
+
+// empty reports whether nothing changed during the period
+func (r *Roundup) empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Renamed) == 0
+}
+
+// ParseRoundupSchedule parses a standard five-field cron expression, evaluated
+// in the named time zone. An empty zone uses the local time zone.
+func ParseRoundupSchedule(spec, timezone string) (cron.Schedule, error) {
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid roundup time zone %q: %w", timezone, err)
+		}
+		spec = "CRON_TZ=" + timezone + " " + spec
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid roundup schedule %q: %w", spec, err)
+	}
+	return schedule, nil
+}
+
+// StartRoundup posts a roundup of the emoji changes since the previous one
+// whenever schedule fires, until ctx is done. Runs missed while the notifier
+// was down aren't made up, but the next roundup covers the whole gap.
+func (n *Notifier) StartRoundup(ctx context.Context, schedule cron.Schedule) {
+	go func() {
+		for {
+			next := schedule.Next(time.Now())
+			log.Debug().Time("next", next).Msg("scheduled emoji roundup")
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if err := n.PostRoundup(ctx, n.roundupStart(schedule, next), next); err != nil {
+				log.Error().Err(err).Msg("failed to post emoji roundup")
+			}
+		}
+	}()
+}
+
+// roundupStart returns when the period of a roundup due at began: when the
+// previous roundup was posted, or one schedule interval earlier for the first
+func (n *Notifier) roundupStart(schedule cron.Schedule, at time.Time) time.Time {
+	last, err := n.store.LastRoundup()
+	if err == nil && last.Before(at) {
+		return last
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Warn().Err(err).Msg("failed to load the time of the last roundup")
+	}
+	after := schedule.Next(at)
+	return at.Add(-after.Sub(at))
+}
+
+// PostRoundup posts the emoji changes between from and to to every
+// destination that accepts roundups, with an LLM-written story about the new
+// emojis in each destination's persona. A destination's filters narrow the
+// emojis listed, and nothing is posted where none of them changed. The period
+// counts as rounded up unless every destination failed.
+func (n *Notifier) PostRoundup(ctx context.Context, from, to time.Time) error {
+	roundup, err := n.BuildRoundup(from, to)
+	if err != nil {
+		return err
+	}
+	if roundup.empty() {
+		log.Info().Time("from", from).Time("to", to).Msg("no emoji changes to round up")
+		return n.store.PutLastRoundup(to)
+	}
+
+	shared := make(map[string]generated)
+	var errs []error
+	posted := false
+	for _, dest := range n.destinations {
+		r := roundup.forDestination(dest)
+		if r.empty() {
+			continue
+		}
+		if err := n.postRoundupTo(ctx, dest, r, shared); err != nil {
+			errs = append(errs, fmt.Errorf("destination %q: %w", dest.Name, err))
+			continue
+		}
+		posted = true
+	}
+	if !posted && len(errs) > 0 {
+		return fmt.Errorf("failed to send roundup: %w", errors.Join(errs...))
+	}
+	if err := n.store.PutLastRoundup(to); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// postRoundupTo posts a roundup to a destination, retrying the story and the
+// message like announcements. Destinations with the same persona share the story.
+func (n *Notifier) postRoundupTo(ctx context.Context, dest *Destination, r *Roundup, shared map[string]generated) error {
+	logger := log.With().Str("destination", dest.Name).Logger()
+
+	d := &delivery{kind: roundupKind, dest: dest, shared: shared}
+	if len(r.Added) > 0 {
+		prompt := "emoji roundup story with emoji names: " + strings.Join(r.Added, ", ")
+		err := n.retryRoundup(ctx, func() error { return n.generate(ctx, d, prompt) })
+		if err != nil {
+			// the changes are still worth posting
+			logger.Warn().Err(err).Msg("failed to generate roundup story")
+		}
+	}
+
+	content := buildRoundupAnnouncement(r, d.sentence)
+	content.Channel = dest.Channel
+	if n.logOnly {
+		logger.Info().Str("text", content.Body).Msg("log-only mode, not posting roundup")
+		return nil
+	}
+	err := n.retryRoundup(ctx, func() error {
+		if _, err := n.slackClient.SendMessage(ctx, content); err != nil {
+			return classify(StageSend, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info().
+		Int("added", len(r.Added)).
+		Int("removed", len(r.Removed)).
+		Int("renamed", len(r.Renamed)).
+		Msg("posted emoji roundup")
+	return nil
+}
+
+// retryRoundup runs a stage of posting a roundup until it succeeds or the
+// retry policy for its latest failure's class is exhausted. A roundup that
+// still fails isn't kept in the outbox, as the next one covers its period.
+func (n *Notifier) retryRoundup(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		derr := classify(StageSend, err)
+		policy := defaultRetryPolicies[derr.Class]
+
+		logger := log.With().
+			Err(derr.Err).
+			Str("stage", derr.Stage).
+			Str("class", string(derr.Class)).
+			Int("attempt", attempt).
+			Logger()
+		if attempt >= policy.MaxAttempts {
+			logger.Error().Msg("giving up on roundup")
+			return derr
+		}
+
+		wait := policy.delay(attempt, derr.RetryAfter)
+		logger.Warn().Dur("retry_in", wait).Msg("roundup failed, retrying")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return classify(derr.Stage, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// forDestination narrows a roundup to the emojis a destination accepts in
+// roundups. Renames are kept if the new name is accepted.
+func (r *Roundup) forDestination(dest *Destination) *Roundup {
+	accepts := func(name string) bool { return dest.accepts(roundupKind, name) }
+	f := &Roundup{From: r.From, To: r.To}
+	f.Added = slices.DeleteFunc(slices.Clone(r.Added), func(name string) bool { return !accepts(name) })
+	f.Removed = slices.DeleteFunc(slices.Clone(r.Removed), func(name string) bool { return !accepts(name) })
+	f.Renamed = slices.DeleteFunc(slices.Clone(r.Renamed), func(names [2]string) bool { return !accepts(names[1]) })
+	return f
+}
+
+// BuildRoundup collects the additions, removals and renames recorded in
+// emoji histories between from and to. Additions are listed under the
+// emoji's current name.
+func (n *Notifier) BuildRoundup(from, to time.Time) (*Roundup, error) {
+	period, err := n.store.HistoryBetween(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load emoji history: %w", err)
+	}
+
+	r := &Roundup{From: from, To: to}
+	for name, events := range period {
+		for _, e := range events {
+			switch e.Type {
+			case store.HistoryAdded, store.HistoryAlias, store.HistoryReturned:
+				if !slices.Contains(r.Added, name) {
+					r.Added = append(r.Added, name)
+				}
+			case store.HistoryRemoved:
+				if !slices.Contains(r.Removed, e.Emoji) {
+					r.Removed = append(r.Removed, e.Emoji)
+				}
+			case store.HistoryRenamed:
+				r.Renamed = append(r.Renamed, [2]string{e.PreviousName, e.Emoji})
+			}
+		}
+	}
+
+	slices.Sort(r.Added)
+	slices.Sort(r.Removed)
+	slices.SortFunc(r.Renamed, func(a, b [2]string) int { return strings.Compare(a[0], b[0]) })
+	return r, nil
+}
+
+// buildRoundupAnnouncement builds the Slack message for a roundup
+func buildRoundupAnnouncement(r *Roundup, story string) slack.MessageContent {
+	var text strings.Builder
+	if len(r.Added) > 0 {
+		fmt.Fprintf(&text, "\n\n*Added (%d)*\n%s", len(r.Added), emojiGrid(r.Added))
+	}
+	if len(r.Renamed) > 0 {
+		fmt.Fprintf(&text, "\n\n*Renamed (%d)*", len(r.Renamed))
+		for _, names := range r.Renamed {
+			fmt.Fprintf(&text, "\n`%s` → :%s: `%s`", names[0], names[1], names[1])
+		}
+	}
+	if len(r.Removed) > 0 {
+		fmt.Fprintf(&text, "\n\n*Removed (%d)*\n", len(r.Removed))
+		for i, name := range r.Removed {
+			if i > 0 {
+				text.WriteString("   ")
+			}
+			fmt.Fprintf(&text, "~`%s`~", name)
+		}
+	}
+	if story != "" {
+		text.WriteString("\n\n*The story so far:*\n" + story)
+	}
+	return slack.MessageContent{
+		Header: fmt.Sprintf("EMOJI ROUNDUP %s – %s", r.From.In(r.To.Location()).Format("Jan 2"), r.To.Format("Jan 2")),
+		Body:   strings.TrimPrefix(text.String(), "\n\n"),
+	}
+}
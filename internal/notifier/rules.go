@@ -0,0 +1,274 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/llm"
+)
+
+// imageSizeTimeout bounds looking up the size of an emoji image for rules
+const imageSizeTimeout = 5 * time.Second
+
+// RuleEvent is the emoji change rules are evaluated against
+type RuleEvent struct {
+	Name string
+	// Subtype is Slack's name for the change: add, remove or rename
+	Subtype      string
+	AliasOf      string
+	Uploader     string
+	PreviousName string
+	Time         time.Time
+	// Size is the image's size in bytes, zero for aliases or when unknown
+	Size int64
+}
+
+// Rule decides what happens to emoji changes its CEL condition matches. A
+// matching rule drops the change, routes its announcement to some of the
+// destinations, or has it written with a different system prompt.
+type Rule struct {
+	Name   string   `json:"name"`
+	When   string   `json:"when"`
+	Drop   bool     `json:"drop"`
+	Route  []string `json:"route"`
+	Prompt string   `json:"prompt"`
+
+	// LLM writes sentences with Prompt, which is ignored without one
+	LLM llm.LLMClient `json:"-"`
+
+	program  cel.Program
+	usesSize bool
+}
+
+var ruleEnv = mustRuleEnv()
+
+func mustRuleEnv() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.Variable("name", cel.StringType),
+		cel.Variable("subtype", cel.StringType),
+		cel.Variable("alias_of", cel.StringType),
+		cel.Variable("uploader", cel.StringType),
+		cel.Variable("previous_name", cel.StringType),
+		cel.Variable("time", cel.TimestampType),
+		cel.Variable("size", cel.IntType),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("rule environment is broken: %v", err))
+	}
+	return env
+}
+
+// ParseRules parses and compiles a JSON list of rules, either inline or in the
+// file the value names. Routes must name one of the destinations. An empty
+// value configures none.
+func ParseRules(value string, destinations []*Destination) ([]*Rule, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	source := []byte(value)
+	if !strings.HasPrefix(value, "[") {
+		var err error
+		if source, err = os.ReadFile(value); err != nil {
+			return nil, fmt.Errorf("failed to read rules: %w", err)
+		}
+	}
+
+	var rules []*Rule
+	if err := json.Unmarshal(source, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+
+	for i, rule := range rules {
+		if rule == nil {
+			return nil, fmt.Errorf("rule %d is empty", i+1)
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+		rule.Prompt = strings.TrimSpace(rule.Prompt)
+
+		actions := 0
+		for _, set := range []bool{rule.Drop, len(rule.Route) > 0, rule.Prompt != ""} {
+			if set {
+				actions++
+			}
+		}
+		if actions == 0 || (rule.Drop && actions > 1) {
+			return nil, fmt.Errorf("rule %q must either drop, or route and/or set a prompt", rule.Name)
+		}
+		for _, name := range rule.Route {
+			if !slices.ContainsFunc(destinations, func(dest *Destination) bool { return dest.Name == name }) {
+				return nil, fmt.Errorf("rule %q routes to unknown destination %q", rule.Name, name)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// compile checks the rule's condition is a valid boolean expression
+func (r *Rule) compile() error {
+	if strings.TrimSpace(r.When) == "" {
+		return fmt.Errorf("rule %q has no condition", r.Name)
+	}
+	ast, issues := ruleEnv.Compile(r.When)
+	if issues.Err() != nil {
+		return fmt.Errorf("invalid condition for rule %q: %w", r.Name, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return fmt.Errorf("condition for rule %q must be true or false, not %s", r.Name, ast.OutputType())
+	}
+
+	program, err := ruleEnv.Program(ast)
+	if err != nil {
+		return fmt.Errorf("invalid condition for rule %q: %w", r.Name, err)
+	}
+	r.program = program
+	for _, ref := range ast.NativeRep().ReferenceMap() {
+		if ref.Name == "size" {
+			r.usesSize = true
+		}
+	}
+	return nil
+}
+
+// Matches evaluates the rule's condition against an event
+func (r *Rule) Matches(ev RuleEvent) (bool, error) {
+	out, _, err := r.program.Eval(map[string]any{
+		"name":          ev.Name,
+		"subtype":       ev.Subtype,
+		"alias_of":      ev.AliasOf,
+		"uploader":      ev.Uploader,
+		"previous_name": ev.PreviousName,
+		"time":          ev.Time,
+		"size":          ev.Size,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate rule %q: %w", r.Name, err)
+	}
+	matched, ok := out.Value().(bool)
+	return ok && matched, nil
+}
+
+// MatchRule returns the first rule whose condition matches an event, or nil.
+// Rules that fail to evaluate are skipped.
+func MatchRule(rules []*Rule, ev RuleEvent) *Rule {
+	for _, rule := range rules {
+		matched, err := rule.Matches(ev)
+		if err != nil {
+			log.Warn().Err(err).Str("emoji", ev.Name).Msg("skipping rule")
+			continue
+		}
+		if matched {
+			return rule
+		}
+	}
+	return nil
+}
+
+// RulesUseSize reports whether any rule looks at the image size, which takes
+// a request to find out
+func RulesUseSize(rules []*Rule) bool {
+	return slices.ContainsFunc(rules, func(r *Rule) bool { return r.usesSize })
+}
+
+// WithRules checks every emoji change against rules before it is announced.
+// Changes a rule drops still update the state, so they aren't announced
+// later by reconciliation either.
+func WithRules(rules []*Rule) Option {
+	return func(n *Notifier) {
+		n.rules = rules
+	}
+}
+
+// applyRules finds the rule matching a change about to be announced and
+// attaches it to the delivery, reporting false if the rule drops the change
+func (n *Notifier) applyRules(ctx context.Context, d *delivery, subtype string) bool {
+	if len(n.rules) == 0 {
+		return true
+	}
+
+	ev := RuleEvent{
+		Name:         d.emoji.Name,
+		Subtype:      subtype,
+		AliasOf:      d.emoji.AliasOf,
+		Uploader:     d.emoji.AddedBy,
+		PreviousName: d.previousName,
+		Time:         time.Now(),
+	}
+	if RulesUseSize(n.rules) && ev.AliasOf == "" {
+		ev.Size = imageSize(ctx, d.emoji.URL)
+	}
+
+	rule := MatchRule(n.rules, ev)
+	if rule == nil {
+		return true
+	}
+	logger := log.With().Str("emoji", d.emoji.Name).Str("subtype", subtype).Str("rule", rule.Name).Logger()
+	if rule.Drop {
+		logger.Info().Msg("rule dropped emoji change")
+		return false
+	}
+	logger.Info().Strs("route", rule.Route).Bool("prompt", rule.Prompt != "").Msg("rule matched emoji change")
+	d.rule = rule
+	return true
+}
+
+// rule returns the named rule, or nil if it is no longer configured
+func (n *Notifier) rule(name string) *Rule {
+	for _, rule := range n.rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// persona returns the destination with the system prompt of a rule, if it sets one
+func (r *Rule) persona(dest *Destination) *Destination {
+	if r == nil || r.Prompt == "" || r.LLM == nil {
+		return dest
+	}
+	withPrompt := *dest
+	withPrompt.SystemPrompt = r.Prompt
+	withPrompt.LLM = r.LLM
+	return &withPrompt
+}
+
+// imageSize asks for the size of an image without downloading it, returning
+// zero if it can't be found out
+func imageSize(ctx context.Context, url string) int64 {
+	if url == "" {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, imageSizeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Debug().Err(err).Str("url", url).Msg("failed to look up emoji image size")
+		return 0
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+		return 0
+	}
+	return resp.ContentLength
+}
@@ -0,0 +1,122 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseRules checks which rule lists are accepted
+func TestParseRules(t *testing.T) {
+	destinations := []*Destination{{Name: "team-channel", Channel: "#team"}}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", value: "  "},
+		{
+			name:  "names default to their position",
+			value: `[{"when": "name == 'a'", "drop": true}, {"name": "team", "when": "true", "route": ["team-channel"]}]`,
+			want:  []string{"rule 1", "team"},
+		},
+		{name: "prompt only", value: `[{"when": "true", "prompt": " Be brief. "}]`, want: []string{"rule 1"}},
+		{name: "invalid json", value: `[{"when": }]`, wantErr: true},
+		{name: "empty rule", value: `[null]`, wantErr: true},
+		{name: "no condition", value: `[{"drop": true}]`, wantErr: true},
+		{name: "invalid condition", value: `[{"when": "name ==", "drop": true}]`, wantErr: true},
+		{name: "unknown variable", value: `[{"when": "uploaded_by == 'U1'", "drop": true}]`, wantErr: true},
+		{name: "condition isn't a bool", value: `[{"when": "name", "drop": true}]`, wantErr: true},
+		{name: "no action", value: `[{"when": "true"}]`, wantErr: true},
+		{name: "drop and route", value: `[{"when": "true", "drop": true, "route": ["team-channel"]}]`, wantErr: true},
+		{name: "unknown destination", value: `[{"when": "true", "route": ["nowhere"]}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseRules(tt.value, destinations)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseRules(%s) succeeded, want an error", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRules(%s): %v", tt.value, err)
+			}
+			if len(rules) != len(tt.want) {
+				t.Fatalf("got %d rules, want %d", len(rules), len(tt.want))
+			}
+			for i, rule := range rules {
+				if rule.Name != tt.want[i] {
+					t.Errorf("rule %d is named %q, want %q", i+1, rule.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestRuleMatches checks conditions against the variables rules can use
+func TestRuleMatches(t *testing.T) {
+	at := time.Date(2026, time.January, 1, 23, 30, 0, 0, time.UTC)
+	ev := RuleEvent{Name: "team-rocket", Subtype: "add", Time: at, Size: 200000}
+
+	tests := []struct {
+		when string
+		ev   RuleEvent
+		want bool
+	}{
+		{when: "name.startsWith('team-')", ev: ev, want: true},
+		{when: "name.matches('^blob_')", ev: ev},
+		{when: "subtype == 'add' && alias_of == ''", ev: ev, want: true},
+		{when: "alias_of == 'cat'", ev: RuleEvent{Name: "kitty", Subtype: "add", AliasOf: "cat"}, want: true},
+		{when: "uploader in ['U0123BOT']", ev: RuleEvent{Name: "blob_wave", Subtype: "add", Uploader: "U0123BOT"}, want: true},
+		{when: "uploader == ''", ev: ev, want: true},
+		{when: "previous_name == 'rocket'", ev: RuleEvent{Name: "team-rocket", Subtype: "rename", PreviousName: "rocket"}, want: true},
+		{when: "size > 100000", ev: ev, want: true},
+		{when: "size > 100000", ev: RuleEvent{Name: "small"}},
+		{when: "time.getHours() >= 22", ev: ev, want: true},
+		{when: "time.getHours('Asia/Tokyo') >= 22", ev: ev},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			rules, err := ParseRules(`[{"when": "`+tt.when+`", "drop": true}]`, nil)
+			if err != nil {
+				t.Fatalf("parsing rule: %v", err)
+			}
+			got, err := rules[0].Matches(tt.ev)
+			if err != nil {
+				t.Fatalf("Matches: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Matches(%+v) = %v, want %v", tt.ev, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMatchRule checks that the first matching rule applies
+func TestMatchRule(t *testing.T) {
+	rules, err := ParseRules(`[
+		{"name": "tests", "when": "name.startsWith('test_')", "drop": true},
+		{"name": "all", "when": "true", "prompt": "Be brief."}
+	]`, nil)
+	if err != nil {
+		t.Fatalf("parsing rules: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "test_cat", want: "tests"},
+		{name: "cat", want: "all"},
+	}
+	for _, tt := range tests {
+		if rule := MatchRule(rules, RuleEvent{Name: tt.name, Subtype: "add"}); rule == nil || rule.Name != tt.want {
+			t.Errorf("MatchRule(%q) = %v, want rule %q", tt.name, rule, tt.want)
+		}
+	}
+}
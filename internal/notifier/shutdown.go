@@ -0,0 +1,83 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Shutdown stops accepting jobs and waits for queued and in-flight work, and
+// any held digest, to finish. If ctx is done first, in-flight announcements
+// are canceled and moved to the outbox, and jobs still waiting in the queue
+// are abandoned. Abandoned additions are picked up again by the next reconcile,
+// and held ones that never went out by ResumeHeld on the next start.
+func (n *Notifier) Shutdown(ctx context.Context) error {
+	n.shutdownOnce.Do(func() { close(n.draining) })
+
+	before := n.deadLettered.Load()
+	log.Info().Int("pending", n.queue.stats().Depth).Msg("draining work queue")
+	n.queue.close()
+
+	timedOut := false
+	done := make(chan struct{})
+	go func() {
+		<-n.queue.wait()
+		// workers are done, so nothing else can join a held digest
+		n.drainHeld()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		timedOut = true
+		log.Warn().Msg("shutdown deadline reached, canceling in-flight work")
+		n.cancel()
+		<-done
+	}
+	n.cancel()
+
+	persisted := n.deadLettered.Load() - before
+	abandoned := n.abandonedJobs()
+
+	event := log.Info()
+	if timedOut || persisted > 0 || len(abandoned) > 0 {
+		event = log.Warn()
+	}
+	event.
+		Int64("moved_to_outbox", persisted).
+		Int("abandoned", len(abandoned)).
+		Strs("abandoned_jobs", abandoned).
+		Msg("notifier stopped")
+
+	if timedOut {
+		return fmt.Errorf("shutdown timed out: %d announcements moved to the outbox, %d jobs abandoned", persisted, len(abandoned))
+	}
+	return nil
+}
+
+// abandon records a job that was dropped by the queue policy or because the
+// notifier is shutting down, so it is reported as lost work
+func (n *Notifier) abandon(j job) {
+	log.Warn().Str("kind", string(j.kind)).Str("emoji", j.name).Msg("abandoning job")
+
+	n.abandonedMu.Lock()
+	defer n.abandonedMu.Unlock()
+	n.abandoned = append(n.abandoned, string(j.kind)+":"+j.name)
+}
+
+func (n *Notifier) abandonedJobs() []string {
+	n.abandonedMu.Lock()
+	defer n.abandonedMu.Unlock()
+	return append([]string(nil), n.abandoned...)
+}
+
+// shuttingDown reports whether Shutdown has been called
+func (n *Notifier) shuttingDown() bool {
+	select {
+	case <-n.draining:
+		return true
+	default:
+		return false
+	}
+}
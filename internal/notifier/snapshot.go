@@ -0,0 +1,142 @@
+package notifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+const imageFetchTimeout = 10 * time.Second
+
+// TakeSnapshot stores the current workspace emoji catalog with a content hash
+// of every image. Images are only downloaded when their URL is new since the
+// previous snapshot.
+func (n *Notifier) TakeSnapshot(ctx context.Context) (*store.Snapshot, error) {
+	current, err := n.slackClient.ListEmojis(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list emojis: %w", err)
+	}
+
+	hashes := make(map[string]string)
+	previous, err := n.store.SnapshotAt(time.Now())
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("failed to load previous snapshot: %w", err)
+	}
+	if previous != nil {
+		for _, e := range previous.Emojis {
+			if e.Hash != "" {
+				hashes[e.URL] = e.Hash
+			}
+		}
+	}
+
+	snap := &store.Snapshot{TakenAt: time.Now(), Emojis: make([]store.SnapshotEmoji, 0, len(current))}
+	fetched, failed := 0, 0
+	for name, value := range current {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		e := store.SnapshotEmoji{Name: name, URL: value}
+		if target, ok := parseAlias(value); ok {
+			e.AliasOf = target
+		} else if hash, ok := hashes[value]; ok {
+			e.Hash = hash
+		} else if hash, err := hashImage(ctx, value); err != nil {
+			// the next snapshot tries again
+			log.Debug().Err(err).Str("emoji", name).Msg("failed to hash emoji image")
+			failed++
+		} else {
+			e.Hash = hash
+			fetched++
+		}
+		snap.Emojis = append(snap.Emojis, e)
+	}
+	sort.Slice(snap.Emojis, func(i, j int) bool { return snap.Emojis[i].Name < snap.Emojis[j].Name })
+
+	if err := n.store.PutSnapshot(snap); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	log.Info().Int("emojis", len(snap.Emojis)).Int("fetched", fetched).Int("failed", failed).Msg("took emoji catalog snapshot")
+	return snap, nil
+}
+
+// hashImage downloads an emoji image and returns the hex SHA-256 of its content
+func hashImage(ctx context.Context, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, imageFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// StartSnapshotter takes a catalog snapshot whenever the latest one is older
+// than interval, and forgets snapshots older than retention, until ctx is done.
+// A non-positive retention keeps every snapshot.
+func (n *Notifier) StartSnapshotter(ctx context.Context, interval, retention time.Duration) {
+	go func() {
+		timer := time.NewTimer(n.nextSnapshotIn(interval))
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			if _, err := n.TakeSnapshot(ctx); err != nil {
+				log.Error().Err(err).Msg("failed to take emoji catalog snapshot")
+			}
+			if retention > 0 {
+				removed, err := n.store.PruneSnapshots(time.Now().Add(-retention))
+				if err != nil {
+					log.Error().Err(err).Msg("failed to prune emoji catalog snapshots")
+				} else if removed > 0 {
+					log.Debug().Int("removed", removed).Msg("pruned emoji catalog snapshots")
+				}
+			}
+			timer.Reset(interval)
+		}
+	}()
+}
+
+// nextSnapshotIn returns how long until the next snapshot is due, so restarts
+// don't take one more often than interval
+func (n *Notifier) nextSnapshotIn(interval time.Duration) time.Duration {
+	times, err := n.store.SnapshotTimes()
+	if err != nil || len(times) == 0 {
+		return 0
+	}
+	if wait := time.Until(times[len(times)-1].Add(interval)); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -0,0 +1,265 @@
+package notifier
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+)
+
+// Announcements rendered from templates. Each is a file named <kind>.tmpl.
+const (
+	TemplateAdd     = "add"
+	TemplateAlias   = "alias"
+	TemplateRename  = "rename"
+	TemplateRemove  = "remove"
+	TemplateDigest  = "digest"
+	TemplateReplace = "replace"
+	TemplateReturn  = "return"
+	TemplatePack    = "pack"
+	// TemplateStruck rewrites an earlier announcement whose emoji was removed or replaced
+	TemplateStruck = "struck"
+)
+
+// TemplateKinds lists every announcement that can be templated
+var TemplateKinds = []string{TemplateAdd, TemplateAlias, TemplateRename, TemplateRemove, TemplateDigest, TemplateReplace, TemplateReturn, TemplatePack, TemplateStruck}
+
+// Statuses of a struck announcement
+const (
+	statusRemoved  = "removed"
+	statusReplaced = "replaced"
+)
+
+// parts of a message a template may define, as {{define "header"}}...{{end}}
+const (
+	partHeader  = "header"
+	partBody    = "body"
+	partText    = "text"
+	partContext = "context"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplateFiles embed.FS
+
+// TemplateData is what message templates can refer to
+type TemplateData struct {
+	// Name is the emoji, or the first of a digest
+	Name string
+	// ImageURL is the emoji's image, empty for aliases
+	ImageURL     string
+	Sentence     string
+	Uploader     string
+	AliasOf      string
+	PreviousName string
+	// PreviousImageURL is a replaced emoji's old image
+	PreviousImageURL string
+	// Earlier links to a returning emoji's first announcement, when known
+	Earlier string
+	// Pack is the naming pattern a pack shares, like blob_*
+	Pack string
+	// Status is why an announcement is struck: removed or replaced
+	Status string
+	// Names lists every emoji in a digest or pack
+	Names []string
+	// ImageURLs are the images of a digest's emojis, in the order of Names
+	ImageURLs []string
+	Count     int
+	Time      time.Time
+}
+
+// Templates renders announcements from text/template files. Each file defines
+// some of the "header", "body", "text" and "context" templates, which fill in
+// the message's header, mrkdwn body, notification fallback and context line.
+// Context is split into one element per line.
+type Templates struct {
+	byKind map[string]*template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"grid": emojiGrid,
+	"join": strings.Join,
+}
+
+// LoadTemplates loads the default templates, replacing any that have a
+// <kind>.tmpl file in dir. An empty dir uses only the defaults. Every template
+// is test rendered with sample data so mistakes surface at startup.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{byKind: make(map[string]*template.Template)}
+	for _, kind := range TemplateKinds {
+		name := kind + ".tmpl"
+		source, err := fs.ReadFile(defaultTemplateFiles, "templates/"+name)
+		if err != nil {
+			return nil, err
+		}
+		if dir != "" {
+			custom, err := os.ReadFile(filepath.Join(dir, name))
+			switch {
+			case err == nil:
+				source = custom
+				log.Debug().Str("template", name).Str("dir", dir).Msg("using custom message template")
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, fmt.Errorf("failed to read template: %w", err)
+			}
+		}
+
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(source))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+		t.byKind[kind] = tmpl
+		if _, err := t.Render(kind, SampleTemplateData(kind)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// builtinTemplates are the defaults, also used when a custom template fails
+var builtinTemplates = mustLoadDefaultTemplates()
+
+func mustLoadDefaultTemplates() *Templates {
+	t, err := LoadTemplates("")
+	if err != nil {
+		panic(fmt.Sprintf("default message templates are broken: %v", err))
+	}
+	return t
+}
+
+// Render builds the message for an announcement from its template. Images
+// aren't templated and are left for the caller to add.
+func (t *Templates) Render(kind string, data TemplateData) (slack.MessageContent, error) {
+	tmpl, ok := t.byKind[kind]
+	if !ok {
+		return slack.MessageContent{}, fmt.Errorf("no template for %q announcements", kind)
+	}
+
+	var content slack.MessageContent
+	for _, part := range []string{partHeader, partBody, partText, partContext} {
+		if tmpl.Lookup(part) == nil {
+			continue
+		}
+		var out bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&out, part, data); err != nil {
+			return slack.MessageContent{}, fmt.Errorf("failed to render template %s: %w", tmpl.Name(), err)
+		}
+		text := strings.TrimSpace(out.String())
+
+		switch part {
+		case partHeader:
+			content.Header = text
+		case partBody:
+			content.Body = text
+		case partText:
+			content.Text = text
+		case partContext:
+			for _, line := range strings.Split(text, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					content.Context = append(content.Context, line)
+				}
+			}
+		}
+	}
+	if content.Header == "" && content.Body == "" && content.Text == "" {
+		return slack.MessageContent{}, fmt.Errorf("template %s renders an empty message", tmpl.Name())
+	}
+	return content, nil
+}
+
+// SampleTemplateData returns example data for previewing an announcement
+func SampleTemplateData(kind string) TemplateData {
+	data := TemplateData{
+		Name:     "party_parrot",
+		ImageURL: "https://emoji.slack-edge.com/T0000000000/party_parrot/0123456789abcdef.gif",
+		Sentence: "When the flaky test finally passes on the third retry :party_parrot:",
+		Uploader: "U0123456789",
+		Count:    1,
+		Time:     time.Date(2026, time.April, 1, 9, 30, 0, 0, time.UTC),
+	}
+	data.Names = []string{data.Name}
+
+	switch kind {
+	case TemplateAlias:
+		data.Name, data.AliasOf, data.Sentence = "pp", "party_parrot", ""
+		data.Names = []string{data.Name}
+	case TemplateRename:
+		data.PreviousName = "partyparrot"
+		data.Sentence = "Same parrot, fancier name."
+	case TemplateRemove:
+		data.Sentence = "Fly free, little parrot."
+	case TemplateReplace:
+		data.PreviousImageURL = "https://emoji.slack-edge.com/T0000000000/party_parrot/fedcba9876543210.gif"
+		data.Sentence = "Same parrot, now in HD."
+	case TemplateReturn:
+		data.Earlier = "<https://example.slack.com/archives/C0000000000/p1767225600000000|Originally announced 2026-01-01>"
+		data.Sentence = "The parrot has returned from its sabbatical."
+	case TemplatePack:
+		data.Name, data.Pack = "blob_dance", "blob_*"
+		data.Names = []string{"blob_dance", "blob_nod", "blob_wave"}
+		data.Count = len(data.Names)
+		data.Sentence = "The blobs have assembled, and they have moves."
+	case TemplateStruck:
+		data.Status = statusRemoved
+	case TemplateDigest:
+		data.Names = []string{"party_parrot", "sad_parrot", "deal_with_it_parrot", "blob_wave", "blob_dance", "catjam"}
+		data.ImageURLs = make([]string, len(data.Names))
+		for i, name := range data.Names {
+			data.ImageURLs[i] = fmt.Sprintf("https://emoji.slack-edge.com/T0000000000/%s/0123456789abcdef.gif", name)
+		}
+		data.Count = len(data.Names)
+		data.Sentence = "The parrots have formed a union and the blobs are negotiating."
+	}
+	return data
+}
+
+// Preview renders an announcement as it would be posted about an emoji with
+// the given data, including the images shown with additions and digests
+func (t *Templates) Preview(kind string, data TemplateData) (slack.MessageContent, error) {
+	content, err := t.Render(kind, data)
+	if err != nil {
+		return slack.MessageContent{}, err
+	}
+	switch kind {
+	case TemplateAdd, TemplateReturn:
+		content.Images = emojiImage(data.Name, data.ImageURL, data.Name)
+	case TemplateReplace:
+		content.Images = replacedImages(data.Name, data.PreviousImageURL, data.ImageURL)
+	case TemplateDigest:
+		content.Grid = emojiGridImages(data.Names, data.ImageURLs)
+	}
+	return content, nil
+}
+
+// WithTemplates renders announcements from the given templates instead of the
+// defaults, for every destination
+// without templates of its own
+func WithTemplates(t *Templates) Option {
+	return func(n *Notifier) {
+		if t != nil {
+			n.templates = t
+		}
+	}
+}
+
+// render builds an announcement from its template, falling back to the
+// default template if a custom one fails on this data
+func (t *Templates) render(kind string, data TemplateData) slack.MessageContent {
+	content, err := t.Render(kind, data)
+	if err == nil {
+		return content
+	}
+	log.Error().Err(err).Str("emoji", data.Name).Msg("failed to render message template, using the default")
+
+	// the defaults only refer to fields every announcement has
+	content, _ = builtinTemplates.Render(kind, data)
+	return content
+}
@@ -0,0 +1,121 @@
+package notifier
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current output")
+
+// TestDefaultTemplates pins the Block Kit payload of every built-in template
+// rendered with the sample data. Run with -update after changing a template.
+func TestDefaultTemplates(t *testing.T) {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatalf("loading default templates: %v", err)
+	}
+
+	for _, kind := range TemplateKinds {
+		t.Run(kind, func(t *testing.T) {
+			content, err := templates.Preview(kind, SampleTemplateData(kind))
+			if err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			got, err := json.MarshalIndent(map[string]interface{}{"text": content.Fallback(), "blocks": content.Blocks()}, "", "  ")
+			if err != nil {
+				t.Fatalf("marshaling blocks: %v", err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", kind+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatalf("updating golden file: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file: %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("output differs from %s:\n--- got\n%s\n--- want\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// TestCustomTemplates checks that a custom template replaces only its own
+// default and that broken templates are rejected when loaded
+func TestCustomTemplates(t *testing.T) {
+	dir := t.TempDir()
+	custom := `{{define "header"}}Fresh emoji{{end}}{{define "body"}}:{{.Name}}: by {{.Uploader}}{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "add.tmpl"), []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("loading custom templates: %v", err)
+	}
+	content, err := templates.Render(TemplateAdd, SampleTemplateData(TemplateAdd))
+	if err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+	if content.Header != "Fresh emoji" || content.Body != ":party_parrot: by U0123456789" {
+		t.Errorf("custom add template not used, got header %q and body %q", content.Header, content.Body)
+	}
+	if content.Fallback() != "Fresh emoji" {
+		t.Errorf("fallback should default to the header, got %q", content.Fallback())
+	}
+
+	content, err = templates.Render(TemplateRemove, SampleTemplateData(TemplateRemove))
+	if err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+	if content.Header != "EMOJI REMOVED" {
+		t.Errorf("remove template should stay the default, got header %q", content.Header)
+	}
+
+	for name, broken := range map[string]string{
+		"syntax":  `{{define "body"}}{{.Name}{{end}}`,
+		"field":   `{{define "body"}}{{.Nope}}{{end}}`,
+		"nothing": `{{define "other"}}unused{{end}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, "add.tmpl"), []byte(broken), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadTemplates(dir); err == nil {
+			t.Errorf("%s: expected loading a broken template to fail", name)
+		}
+	}
+}
+
+// TestUploaderMention checks that the add announcement only mentions the
+// uploader when it is known
+func TestUploaderMention(t *testing.T) {
+	tests := []struct {
+		uploader string
+		want     []string
+	}{
+		{"U0123456789", []string{"`:party_parrot:`", "Added by <@U0123456789>"}},
+		{"", []string{"`:party_parrot:`"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uploader, func(t *testing.T) {
+			data := SampleTemplateData(TemplateAdd)
+			data.Uploader = tt.uploader
+			content, err := builtinTemplates.Render(TemplateAdd, data)
+			if err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			if !slices.Equal(content.Context, tt.want) {
+				t.Errorf("context = %q, want %q", content.Context, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/particledecay/slackmoji-notifier/pkg/slack"
+	"github.com/particledecay/slackmoji-notifier/pkg/store"
+)
+
+// WithDailyThread posts the day's announcements of new emojis as replies under
+// a single parent message per channel, which keeps a running list of the day's
+// names. Days follow the local time zone.
+func WithDailyThread(enabled bool) Option {
+	return func(n *Notifier) {
+		n.dailyThread = enabled
+	}
+}
+
+// threaded reports whether an announcement belongs in the daily thread. Only
+// new emojis do; renames, removals and new images are posted on their own.
+func (n *Notifier) threaded(d *delivery, content slack.MessageContent) bool {
+	if !n.dailyThread {
+		return false
+	}
+	switch d.kind {
+	case "", announceAdd, announceReturn, announceDigest, announcePack:
+		return true
+	default:
+		return false
+	}
+}
+
+// postInDailyThread replies to today's parent message in the announcement's
+// channel, creating it first if this is the channel's first announcement of
+// the day, and adds the emojis to its list
+func (n *Notifier) postInDailyThread(ctx context.Context, d *delivery, content slack.MessageContent) (slack.MessageRef, error) {
+	parent, err := n.dailyParent(ctx, content.Channel)
+	if err != nil {
+		return slack.MessageRef{}, err
+	}
+
+	ref, err := n.slackClient.ReplyInThread(ctx, parent, content)
+	if err != nil {
+		return slack.MessageRef{}, err
+	}
+
+	n.addToDailyThread(ctx, content.Channel, emojiNames(d.emojis()))
+	return ref, nil
+}
+
+// dailyParent returns today's parent message in a channel, empty for the
+// default one, posting it if there isn't one yet
+func (n *Notifier) dailyParent(ctx context.Context, channel string) (slack.MessageRef, error) {
+	n.threadMu.Lock()
+	defer n.threadMu.Unlock()
+
+	today := time.Now().Format(time.DateOnly)
+	thread, err := n.store.DailyThread(channel)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return slack.MessageRef{}, fmt.Errorf("failed to load daily thread: %w", err)
+	}
+	if thread != nil && thread.Day == today {
+		return slack.MessageRef{Channel: thread.Channel, Timestamp: thread.Timestamp}, nil
+	}
+
+	parent := buildDailyParent(today, nil)
+	parent.Channel = channel
+	ref, err := n.slackClient.SendMessage(ctx, parent)
+	if err != nil {
+		return slack.MessageRef{}, err
+	}
+	log.Info().Str("day", today).Str("channel", channel).Str("ts", ref.Timestamp).Msg("started daily announcement thread")
+
+	thread = &store.DailyThread{Destination: channel, Day: today, Channel: ref.Channel, Timestamp: ref.Timestamp}
+	if err := n.store.PutDailyThread(thread); err != nil {
+		// the thread still works until the next restart
+		log.Error().Err(err).Msg("failed to save daily thread")
+	}
+	return ref, nil
+}
+
+// addToDailyThread adds names to the list on today's parent message in a channel
+func (n *Notifier) addToDailyThread(ctx context.Context, channel string, names []string) {
+	n.threadMu.Lock()
+	defer n.threadMu.Unlock()
+
+	thread, err := n.store.DailyThread(channel)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load daily thread")
+		return
+	}
+	for _, name := range names {
+		if !slices.Contains(thread.Names, name) {
+			thread.Names = append(thread.Names, name)
+		}
+	}
+	if err := n.store.PutDailyThread(thread); err != nil {
+		log.Error().Err(err).Msg("failed to save daily thread")
+	}
+
+	ref := slack.MessageRef{Channel: thread.Channel, Timestamp: thread.Timestamp}
+	if err := n.slackClient.UpdateMessage(ctx, ref, buildDailyParent(thread.Day, thread.Names)); err != nil {
+		log.Warn().Err(err).Str("ts", ref.Timestamp).Msg("failed to update daily thread")
+	}
+}
+
+// buildDailyParent builds the parent message of a day's thread with the names announced so far
+func buildDailyParent(day string, names []string) slack.MessageContent {
+	content := slack.MessageContent{Header: fmt.Sprintf("Today's new emojis (%s)", day)}
+	if len(names) > 0 {
+		content.Body = fmt.Sprintf("%d so far, see the thread for details\n%s", len(names), emojiGrid(names))
+	}
+	return content
+}
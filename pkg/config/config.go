@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
@@ -21,6 +22,50 @@ const (
 	defaultGoogleAIModel      = "gemini-2.5-flash-lite"
 	defaultGoogleAIMaxTokens  = 1024
 	defaultSlackLogOnly       = "false"
+	defaultSlackMode          = ModeSocket
+	defaultSlackHTTPAddr      = ":3000"
+	defaultSlackHTTPPath      = "/slack/events"
+	defaultPollInterval       = 1 * time.Minute
+	defaultReconcileInterval  = 15 * time.Minute
+	defaultDedupeWindow       = 1 * time.Hour
+	defaultEventQueueSize     = 100
+	defaultReconnectBackoff   = 1 * time.Second
+	defaultReconnectMax       = 2 * time.Minute
+	defaultStaleTimeout       = 5 * time.Minute
+	defaultWorkers            = 4
+	defaultQueueSize          = 100
+	defaultQueuePolicy        = "block"
+	defaultAliasMode          = "announce"
+	defaultRenameNotice       = "off"
+	defaultRemovalNotice      = "off"
+	defaultReplaceWindow      = 10 * time.Minute
+	defaultBurstThreshold     = 5
+	defaultBurstWindow        = 1 * time.Minute
+	defaultPackWindow         = 1 * time.Minute
+	defaultPackMinSize        = 3
+	defaultDailyThread        = false
+	defaultRoundupSchedule    = ""
+	defaultRoundupTimezone    = ""
+	defaultQuietHours         = ""
+	defaultTemplateDir        = ""
+	defaultDestinations       = ""
+	defaultRules              = ""
+	defaultButtons            = false
+	defaultStateDriver        = "bolt"
+	defaultStatePath          = "slackmoji-notifier.db"
+	defaultSlackAPITimeout    = 10 * time.Second
+	defaultLLMTimeout         = 30 * time.Second
+	defaultOllamaTimeout      = 2 * time.Minute
+	defaultShutdownTimeout    = 20 * time.Second
+	defaultSnapshotInterval   = 24 * time.Hour
+	defaultSnapshotRetention  = 180 * 24 * time.Hour
+)
+
+// Slack listen modes
+const (
+	ModeSocket = "socket"
+	ModeHTTP   = "http"
+	ModePoll   = "poll"
 )
 
 const defaultSystemPrompt = `
@@ -33,32 +78,86 @@ emoji name is "smile", the included string should be ":smile:". Don't use other
 
 type Config struct {
 	Slack struct {
-		BotToken string
-		AppToken string
-		Channel  string
-		LogOnly  bool
+		BotToken          string
+		AppToken          string
+		SigningSecret     string
+		AdminToken        string
+		Channel           string
+		LogOnly           bool
+		Mode              string
+		HTTPAddr          string
+		HTTPPath          string
+		PollInterval      time.Duration
+		ReconcileInterval time.Duration
+		DedupeWindow      time.Duration
+		EventQueueSize    int
+		ReconnectBackoff  time.Duration
+		ReconnectMax      time.Duration
+		StaleTimeout      time.Duration
+		APITimeout        time.Duration
+		RemovalChannel    string
 	}
 	OpenAI struct {
 		APIKey    string
 		Model     string
 		MaxTokens int
+		Timeout   time.Duration
 	}
 	Ollama struct {
 		Model   string
 		BaseURL string
+		Timeout time.Duration
 	}
 	Anthropic struct {
 		APIKey    string
 		Model     string
 		MaxTokens int
+		Timeout   time.Duration
 	}
 	GoogleAI struct {
 		APIKey    string
 		Model     string
 		MaxTokens int
+		Timeout   time.Duration
+	}
+	Notifier struct {
+		Workers       int
+		QueueSize     int
+		QueuePolicy   string
+		AliasMode     string
+		RenameNotice  string
+		RemovalNotice string
+		ReplaceWindow time.Duration
+
+		BurstThreshold int
+		BurstWindow    time.Duration
+		PackWindow     time.Duration
+		PackMinSize    int
+		DailyThread    bool
+		QuietHours     string
+		TemplateDir    string
+		Destinations   string
+		Rules          string
+		Buttons        bool
+		Admins         []string
+		Editors        []string
+	}
+	State struct {
+		Driver string
+		Path   string
+	}
+	Snapshot struct {
+		Interval  time.Duration
+		Retention time.Duration
+	}
+	Roundup struct {
+		Schedule string
+		Timezone string
 	}
-	LLMProvider  string
-	SystemPrompt string
+	HealthAddr      string
+	ShutdownTimeout time.Duration
+	LLMProvider     string
+	SystemPrompt    string
 }
 
 func New() *Config {
@@ -68,6 +167,7 @@ func New() *Config {
 	config.Slack.BotToken = os.Getenv("SLACK_BOT_TOKEN")
 	config.Slack.AppToken = os.Getenv("SLACK_APP_TOKEN")
 	config.Slack.Channel = os.Getenv("SLACK_CHANNEL")
+	config.Slack.RemovalChannel = os.Getenv("SLACK_REMOVAL_CHANNEL")
 	logOnlyValue := os.Getenv("SLACK_LOG_ONLY")
 	if logOnlyValue == "" {
 		logOnlyValue = defaultSlackLogOnly
@@ -76,6 +176,59 @@ func New() *Config {
 	}
 	logOnly, _ := strconv.ParseBool(logOnlyValue)
 	config.Slack.LogOnly = logOnly
+	config.Slack.SigningSecret = os.Getenv("SLACK_SIGNING_SECRET")
+	config.Slack.AdminToken = os.Getenv("SLACK_ADMIN_TOKEN")
+	config.Slack.Mode = getStringEnvOrDefault("SLACK_MODE", defaultSlackMode)
+	if getBoolEnvOrDefault("SLACK_POLL", false) {
+		config.Slack.Mode = ModePoll
+	}
+	config.Slack.HTTPAddr = getStringEnvOrDefault("SLACK_HTTP_ADDR", defaultSlackHTTPAddr)
+	config.Slack.HTTPPath = getStringEnvOrDefault("SLACK_HTTP_PATH", defaultSlackHTTPPath)
+	config.Slack.PollInterval = getDurationEnvOrDefault("SLACK_POLL_INTERVAL", defaultPollInterval)
+	config.Slack.ReconcileInterval = getDurationEnvOrDefault("SLACK_RECONCILE_INTERVAL", defaultReconcileInterval)
+	config.Slack.DedupeWindow = getDurationEnvOrDefault("SLACK_DEDUPE_WINDOW", defaultDedupeWindow)
+	config.Slack.EventQueueSize = getIntEnvOrDefault("SLACK_EVENT_QUEUE_SIZE", defaultEventQueueSize)
+	config.Slack.ReconnectBackoff = getDurationEnvOrDefault("SLACK_RECONNECT_BACKOFF", defaultReconnectBackoff)
+	config.Slack.ReconnectMax = getDurationEnvOrDefault("SLACK_RECONNECT_MAX", defaultReconnectMax)
+	config.Slack.StaleTimeout = getDurationEnvOrDefault("SLACK_STALE_TIMEOUT", defaultStaleTimeout)
+	config.Slack.APITimeout = getDurationEnvOrDefault("SLACK_API_TIMEOUT", defaultSlackAPITimeout)
+
+	// the health server is disabled unless an address is set
+	config.HealthAddr = os.Getenv("HEALTH_ADDR")
+	config.ShutdownTimeout = getDurationEnvOrDefault("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+
+	log.Debug().Msg("setting notifier configuration")
+	config.Notifier.Workers = getIntEnvOrDefault("NOTIFIER_WORKERS", defaultWorkers)
+	config.Notifier.QueueSize = getIntEnvOrDefault("NOTIFIER_QUEUE_SIZE", defaultQueueSize)
+	config.Notifier.QueuePolicy = getStringEnvOrDefault("NOTIFIER_QUEUE_POLICY", defaultQueuePolicy)
+	config.Notifier.AliasMode = getStringEnvOrDefault("NOTIFIER_ALIAS_MODE", defaultAliasMode)
+	config.Notifier.RenameNotice = getStringEnvOrDefault("NOTIFIER_RENAME_NOTICE", defaultRenameNotice)
+	config.Notifier.RemovalNotice = getStringEnvOrDefault("NOTIFIER_REMOVAL_NOTICE", defaultRemovalNotice)
+	config.Notifier.ReplaceWindow = getDurationEnvOrDefault("NOTIFIER_REPLACE_WINDOW", defaultReplaceWindow)
+	config.Notifier.BurstThreshold = getIntEnvOrDefault("NOTIFIER_BURST_THRESHOLD", defaultBurstThreshold)
+	config.Notifier.BurstWindow = getDurationEnvOrDefault("NOTIFIER_BURST_WINDOW", defaultBurstWindow)
+	config.Notifier.PackWindow = getDurationEnvOrDefault("NOTIFIER_PACK_WINDOW", defaultPackWindow)
+	config.Notifier.PackMinSize = getIntEnvOrDefault("NOTIFIER_PACK_MIN_SIZE", defaultPackMinSize)
+	config.Notifier.DailyThread = getBoolEnvOrDefault("NOTIFIER_DAILY_THREAD", defaultDailyThread)
+	config.Notifier.QuietHours = getStringEnvOrDefault("NOTIFIER_QUIET_HOURS", defaultQuietHours)
+	config.Notifier.TemplateDir = getStringEnvOrDefault("NOTIFIER_TEMPLATE_DIR", defaultTemplateDir)
+	config.Notifier.Destinations = getStringEnvOrDefault("NOTIFIER_DESTINATIONS", defaultDestinations)
+	config.Notifier.Rules = getStringEnvOrDefault("NOTIFIER_RULES", defaultRules)
+	config.Notifier.Buttons = getBoolEnvOrDefault("NOTIFIER_BUTTONS", defaultButtons)
+	config.Notifier.Admins = getListEnv("NOTIFIER_ADMINS")
+	config.Notifier.Editors = getListEnv("NOTIFIER_EDITORS")
+
+	log.Debug().Msg("setting state configuration")
+	config.State.Driver = getStringEnvOrDefault("STATE_DRIVER", defaultStateDriver)
+	config.State.Path = getStringEnvOrDefault("STATE_PATH", defaultStatePath)
+
+	log.Debug().Msg("setting snapshot configuration")
+	config.Snapshot.Interval = getDurationEnvOrDefault("SNAPSHOT_INTERVAL", defaultSnapshotInterval)
+	config.Snapshot.Retention = getDurationEnvOrDefault("SNAPSHOT_RETENTION", defaultSnapshotRetention)
+
+	log.Debug().Msg("setting roundup configuration")
+	config.Roundup.Schedule = getStringEnvOrDefault("ROUNDUP_SCHEDULE", defaultRoundupSchedule)
+	config.Roundup.Timezone = getStringEnvOrDefault("ROUNDUP_TIMEZONE", defaultRoundupTimezone)
 
 	log.Debug().Msg("setting LLM configuration")
 	config.LLMProvider = getStringEnvOrDefault("LLM_PROVIDER", defaultLLMProvider)
@@ -95,6 +248,7 @@ func New() *Config {
 			log.Info().Str("OLLAMA_BASE_URL", defaultOllamaBaseURL).Msg("Ollama BaseURL not set, using default")
 			config.Ollama.BaseURL = defaultOllamaBaseURL
 		}
+		config.Ollama.Timeout = getDurationEnvOrDefault("OLLAMA_TIMEOUT", defaultOllamaTimeout)
 		log.Info().Str("model", config.Ollama.Model).Str("baseURL", config.Ollama.BaseURL).Msg("using Ollama model")
 	case "anthropic":
 		setAnthropicConfig(config)
@@ -113,6 +267,7 @@ func setOpenAIConfig(config *Config) {
 	config.OpenAI.APIKey = os.Getenv("OPENAI_API_KEY")
 	config.OpenAI.Model = getStringEnvOrDefault("OPENAI_MODEL", defaultOpenAIModel)
 	config.OpenAI.MaxTokens = getIntEnvOrDefault("OPENAI_MAX_TOKENS", defaultOpenAIMaxTokens)
+	config.OpenAI.Timeout = getDurationEnvOrDefault("OPENAI_TIMEOUT", defaultLLMTimeout)
 	log.Info().Str("model", config.OpenAI.Model).Msg("using OpenAI model")
 }
 
@@ -141,10 +296,54 @@ func getIntEnvOrDefault(envVar string, defaultValue int) int {
 	return parsedValue
 }
 
+func getBoolEnvOrDefault(envVar string, defaultValue bool) bool {
+	valueStr := os.Getenv(envVar)
+	if valueStr == "" {
+		log.Info().Bool(envVar, defaultValue).Msg("environment variable not set, using default")
+		return defaultValue
+	}
+
+	parsedValue, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		log.Warn().Err(err).Str(envVar, valueStr).Bool("default", defaultValue).Msg("error parsing environment variable, using default")
+		return defaultValue
+	}
+
+	return parsedValue
+}
+
+// getListEnv splits a comma-separated environment variable, skipping empty items
+func getListEnv(envVar string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(envVar), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getDurationEnvOrDefault(envVar string, defaultValue time.Duration) time.Duration {
+	valueStr := os.Getenv(envVar)
+	if valueStr == "" {
+		log.Info().Dur(envVar, defaultValue).Msg("environment variable not set, using default")
+		return defaultValue
+	}
+
+	parsedValue, err := time.ParseDuration(valueStr)
+	if err != nil {
+		log.Warn().Err(err).Str(envVar, valueStr).Dur("default", defaultValue).Msg("error parsing environment variable, using default")
+		return defaultValue
+	}
+
+	return parsedValue
+}
+
 func setAnthropicConfig(config *Config) {
 	config.Anthropic.APIKey = os.Getenv("ANTHROPIC_API_KEY")
 	config.Anthropic.Model = getStringEnvOrDefault("ANTHROPIC_MODEL", defaultAnthropicModel)
 	config.Anthropic.MaxTokens = getIntEnvOrDefault("ANTHROPIC_MAX_TOKENS", defaultAnthropicMaxTokens)
+	config.Anthropic.Timeout = getDurationEnvOrDefault("ANTHROPIC_TIMEOUT", defaultLLMTimeout)
 	log.Info().Str("model", config.Anthropic.Model).Msg("using Anthropic model")
 }
 
@@ -152,6 +351,7 @@ func setGoogleAIConfig(config *Config) {
 	config.GoogleAI.APIKey = os.Getenv("GOOGLEAI_API_KEY")
 	config.GoogleAI.Model = getStringEnvOrDefault("GOOGLEAI_MODEL", defaultGoogleAIModel)
 	config.GoogleAI.MaxTokens = getIntEnvOrDefault("GOOGLEAI_MAX_TOKENS", defaultGoogleAIMaxTokens)
+	config.GoogleAI.Timeout = getDurationEnvOrDefault("GOOGLEAI_TIMEOUT", defaultLLMTimeout)
 	log.Info().Str("model", config.GoogleAI.Model).Msg("using GoogleAI model")
 }
 
@@ -160,15 +360,54 @@ func (c *Config) Validate() error {
 		log.Error().Msg("SLACK_BOT_TOKEN is not set")
 		return errors.New("SLACK_BOT_TOKEN is not set")
 	}
-	if c.Slack.AppToken == "" {
-		log.Error().Msg("SLACK_APP_TOKEN is not set")
-		return errors.New("SLACK_APP_TOKEN is not set")
+	switch c.Slack.Mode {
+	case ModeSocket:
+		if c.Slack.AppToken == "" {
+			log.Error().Msg("SLACK_APP_TOKEN is not set")
+			return errors.New("SLACK_APP_TOKEN is not set")
+		}
+	case ModeHTTP:
+		if c.Slack.SigningSecret == "" {
+			log.Error().Msg("SLACK_SIGNING_SECRET is not set")
+			return errors.New("SLACK_SIGNING_SECRET is not set")
+		}
+	case ModePoll:
+		if c.Slack.PollInterval <= 0 {
+			log.Error().Msg("SLACK_POLL_INTERVAL must be positive")
+			return errors.New("SLACK_POLL_INTERVAL must be positive")
+		}
+	default:
+		return fmt.Errorf("unsupported SLACK_MODE: %s", c.Slack.Mode)
 	}
 	if c.Slack.Channel == "" {
 		log.Error().Msg("SLACK_CHANNEL is not set")
 		return errors.New("SLACK_CHANNEL is not set")
 	}
 
+	switch c.Notifier.QueuePolicy {
+	case "block", "drop-newest", "drop-oldest":
+	default:
+		return fmt.Errorf("unsupported NOTIFIER_QUEUE_POLICY: %s", c.Notifier.QueuePolicy)
+	}
+
+	switch c.Notifier.AliasMode {
+	case "announce", "fold", "ignore":
+	default:
+		return fmt.Errorf("unsupported NOTIFIER_ALIAS_MODE: %s", c.Notifier.AliasMode)
+	}
+
+	switch c.Notifier.RenameNotice {
+	case "off", "text", "llm":
+	default:
+		return fmt.Errorf("unsupported NOTIFIER_RENAME_NOTICE: %s", c.Notifier.RenameNotice)
+	}
+
+	switch c.Notifier.RemovalNotice {
+	case "off", "text", "llm":
+	default:
+		return fmt.Errorf("unsupported NOTIFIER_REMOVAL_NOTICE: %s", c.Notifier.RemovalNotice)
+	}
+
 	switch c.LLMProvider {
 	case "openai":
 		if c.OpenAI.APIKey == "" {
@@ -15,6 +15,7 @@ import (
 // LLMClient defines the methods that an LLM client should implement
 type LLMClient interface {
 	GenerateCompletion(ctx context.Context, message string, streamToStdout bool) (string, error)
+	Model() string
 }
 
 // generateContentWithLLM is a helper function that handles the common logic for generating content
@@ -43,16 +44,35 @@ func generateContentWithLLM(ctx context.Context, llm interface{ GenerateContent(
 
 	content, err := llm.GenerateContent(ctx, messageContents, options...)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate content from %s: %w", providerName, err)
+		return "", fmt.Errorf("failed to generate content from %s: %w", providerName, errorMapper(providerName).WrapError(err))
 	}
 
 	if streamToStdout {
 		return "", nil
 	}
 
+	// an empty response says nothing about why, so it is worth retrying
+	if len(content.Choices) == 0 {
+		return "", fmt.Errorf("%s returned no choices: %w", providerName, llms.NewError(llms.ErrCodeUnknown, providerName, "empty response"))
+	}
+
 	return content.Choices[0].Content, nil
 }
 
+// errorMapper returns the mapper that turns provider errors into typed llms errors
+func errorMapper(providerName string) *llms.ErrorMapper {
+	switch providerName {
+	case "OpenAI":
+		return llms.OpenAIErrorMapper()
+	case "Anthropic":
+		return llms.AnthropicErrorMapper()
+	case "GoogleAI":
+		return llms.GoogleAIErrorMapper()
+	default:
+		return llms.NewErrorMapper(providerName)
+	}
+}
+
 // OpenAIClient implements LLMClient for OpenAI models
 type OpenAIClient struct {
 	llm          *openai.LLM
@@ -83,6 +103,11 @@ func (c *OpenAIClient) GenerateCompletion(ctx context.Context, message string, s
 	return generateContentWithLLM(ctx, c.llm, c.systemPrompt, message, c.maxTokens, streamToStdout, "OpenAI")
 }
 
+// Model returns the name of the OpenAI model used for completions
+func (c *OpenAIClient) Model() string {
+	return c.modelName
+}
+
 // OllamaClient implements LLMClient for Ollama models
 type OllamaClient struct {
 	llm           *ollama.LLM
@@ -113,6 +138,11 @@ func (c *OllamaClient) GenerateCompletion(ctx context.Context, message string, s
 	return generateContentWithLLM(ctx, c.llm, c.systemPrompt, message, 0, streamToStdout, "Ollama")
 }
 
+// Model returns the name of the Ollama model used for completions
+func (c *OllamaClient) Model() string {
+	return c.modelName
+}
+
 // AnthropicClient implements LLMClient for Anthropic models
 type AnthropicClient struct {
 	llm          *anthropic.LLM
@@ -143,6 +173,11 @@ func (c *AnthropicClient) GenerateCompletion(ctx context.Context, message string
 	return generateContentWithLLM(ctx, c.llm, c.systemPrompt, message, c.maxTokens, streamToStdout, "Anthropic")
 }
 
+// Model returns the name of the Anthropic model used for completions
+func (c *AnthropicClient) Model() string {
+	return c.modelName
+}
+
 // GoogleAIClient implements LLMClient for Google AI models
 type GoogleAIClient struct {
 	llm          *googleai.GoogleAI
@@ -173,3 +208,8 @@ func NewGoogleAIClient(apiKey, modelName, systemPrompt string, maxTokens int) (*
 func (c *GoogleAIClient) GenerateCompletion(ctx context.Context, message string, streamToStdout bool) (string, error) {
 	return generateContentWithLLM(ctx, c.llm, c.systemPrompt, message, c.maxTokens, streamToStdout, "GoogleAI")
 }
+
+// Model returns the name of the GoogleAI model used for completions
+func (c *GoogleAIClient) Model() string {
+	return c.modelName
+}
@@ -0,0 +1,33 @@
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// timeoutClient bounds every completion of the wrapped client by a fixed timeout
+type timeoutClient struct {
+	client  LLMClient
+	timeout time.Duration
+}
+
+// WithTimeout wraps an LLMClient so each completion is canceled after timeout.
+// A non-positive timeout returns the client unchanged.
+func WithTimeout(client LLMClient, timeout time.Duration) LLMClient {
+	if timeout <= 0 {
+		return client
+	}
+	return &timeoutClient{client: client, timeout: timeout}
+}
+
+// GenerateCompletion delegates to the wrapped client with a bounded context
+func (c *timeoutClient) GenerateCompletion(ctx context.Context, message string, streamToStdout bool) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.client.GenerateCompletion(ctx, message, streamToStdout)
+}
+
+// Model returns the model of the wrapped client
+func (c *timeoutClient) Model() string {
+	return c.client.Model()
+}
@@ -0,0 +1,133 @@
+package slack
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+// Block Kit limits
+const (
+	maxHeaderLength   = 150
+	maxSectionLength  = 3000
+	maxContextEntries = 10
+	maxAltTextLength  = 2000
+	// gridRowSize is how many images share a row of the grid, each taking
+	// two of a context block's elements
+	gridRowSize = maxContextEntries / 2
+)
+
+// Fallback returns the notification text of the message
+func (m MessageContent) Fallback() string {
+	switch {
+	case m.Text != "":
+		return m.Text
+	case m.Header != "":
+		return m.Header
+	default:
+		return m.Body
+	}
+}
+
+// Blocks lays the message out as Block Kit blocks. It never returns nil, so
+// an update always replaces the blocks previously posted.
+func (m MessageContent) Blocks() []slack.Block {
+	blocks := []slack.Block{}
+
+	if m.Header != "" {
+		blocks = append(blocks, slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, truncate(m.Header, maxHeaderLength), true, false)))
+	}
+	blocks = append(blocks, gridBlocks(m.Grid)...)
+	for _, text := range splitSections(m.Body) {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil))
+	}
+	for _, image := range m.Images {
+		// an image block without a URL is rejected along with the whole message
+		if image.URL == "" {
+			continue
+		}
+		alt := image.AltText
+		if alt == "" {
+			alt = image.Title
+		}
+		if alt == "" {
+			alt = "emoji image"
+		}
+		var title *slack.TextBlockObject
+		if image.Title != "" {
+			title = slack.NewTextBlockObject(slack.PlainTextType, image.Title, true, false)
+		}
+		blocks = append(blocks, slack.NewImageBlock(image.URL, truncate(alt, maxAltTextLength), "", title))
+	}
+	if len(m.Context) > 0 {
+		var elements []slack.MixedElement
+		for _, text := range m.Context[:min(len(m.Context), maxContextEntries)] {
+			if text != "" {
+				elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, text, false, false))
+			}
+		}
+		if len(elements) > 0 {
+			blocks = append(blocks, slack.NewContextBlock("", elements...))
+		}
+	}
+	if len(m.Buttons) > 0 {
+		blocks = append(blocks, buttonsBlock(m.Buttons))
+	}
+	return blocks
+}
+
+// gridBlocks lays images out as rows of context blocks, each image followed
+// by its title
+func gridBlocks(images []Image) []slack.Block {
+	var blocks []slack.Block
+	for start := 0; start < len(images); start += gridRowSize {
+		var elements []slack.MixedElement
+		for _, image := range images[start:min(start+gridRowSize, len(images))] {
+			if image.URL != "" {
+				alt := image.AltText
+				if alt == "" {
+					alt = "emoji image"
+				}
+				elements = append(elements, slack.NewImageBlockElement(image.URL, truncate(alt, maxAltTextLength)))
+			}
+			if image.Title != "" {
+				elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, image.Title, false, false))
+			}
+		}
+		if len(elements) > 0 {
+			blocks = append(blocks, slack.NewContextBlock("", elements...))
+		}
+	}
+	return blocks
+}
+
+// splitSections splits mrkdwn into pieces that each fit in a section block,
+// breaking between lines where it can
+func splitSections(text string) []string {
+	var sections []string
+	for text != "" {
+		if len(text) <= maxSectionLength {
+			sections = append(sections, text)
+			break
+		}
+		cut := strings.LastIndex(text[:maxSectionLength], "\n")
+		if cut <= 0 {
+			cut = len(truncate(text, maxSectionLength))
+		}
+		sections = append(sections, text[:cut])
+		text = strings.TrimPrefix(text[cut:], "\n")
+	}
+	return sections
+}
+
+// truncate shortens text to at most limit bytes without splitting a character
+func truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit]
+}
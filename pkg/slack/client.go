@@ -1,7 +1,10 @@
 package slack
 
 import (
+	"context"
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/socketmode"
@@ -13,13 +16,44 @@ type Client struct {
 	socketClient *socketmode.Client
 	channel      string
 	eventHandler EventHandler
-	stopChan     chan struct{}
+	cancel       context.CancelFunc
+	// handlerCtx is passed to handlers and canceled once the queue is drained
+	handlerCtx    context.Context
+	cancelHandler context.CancelFunc
+	httpEvents    *httpEventsConfig
+	queue         chan interface{}
+	queueSize     int
+	queueMu       sync.RWMutex
+	queueClosed   bool
+	drained       chan struct{}
+	acks          ackTracker
+	apiTimeout    time.Duration
+
+	adminToken  string
+	uploadersMu sync.Mutex
+	uploaders   map[string]string
+
+	interactionHandler InteractionHandler
+
+	stateMu      sync.RWMutex
+	state        ConnectionState
+	stateSince   time.Time
+	backoffBase  time.Duration
+	backoffMax   time.Duration
+	staleTimeout time.Duration
 }
 
+const defaultAPITimeout = 10 * time.Second
+
 type ClientOption func(*Client)
 
 func NewClient(options ...ClientOption) (ClientInterface, error) {
-	client := &Client{}
+	client := &Client{
+		backoffBase:  defaultBackoffBase,
+		backoffMax:   defaultBackoffMax,
+		staleTimeout: defaultStaleTimeout,
+		apiTimeout:   defaultAPITimeout,
+	}
 
 	for _, option := range options {
 		option(client)
@@ -29,10 +63,6 @@ func NewClient(options ...ClientOption) (ClientInterface, error) {
 		return nil, errors.New("slack API client must be provided")
 	}
 
-	if client.socketClient == nil {
-		return nil, errors.New("slack socket mode client must be provided")
-	}
-
 	if client.channel == "" {
 		return nil, errors.New("channel name must be provided")
 	}
@@ -51,6 +81,13 @@ func WithAPIToken(botToken, appToken string) ClientOption {
 	}
 }
 
+// WithBotToken configures the API client without Socket Mode, for polling or HTTP events
+func WithBotToken(botToken string) ClientOption {
+	return func(c *Client) {
+		c.api = slack.New(botToken)
+	}
+}
+
 func WithChannel(channel string) ClientOption {
 	return func(c *Client) {
 		c.channel = channel
@@ -62,3 +99,45 @@ func WithEventHandler(handler EventHandler) ClientOption {
 		c.eventHandler = handler
 	}
 }
+
+// WithQueueSize sets how many accepted events may wait for processing
+func WithQueueSize(size int) ClientOption {
+	return func(c *Client) {
+		c.queueSize = size
+	}
+}
+
+// WithReconnectBackoff sets the initial and maximum delay between reconnect attempts
+func WithReconnectBackoff(base, max time.Duration) ClientOption {
+	return func(c *Client) {
+		if base > 0 {
+			c.backoffBase = base
+		}
+		if max >= base && max > 0 {
+			c.backoffMax = max
+		}
+	}
+}
+
+// WithStaleTimeout sets how long the connection may stay disconnected before it is restarted
+func WithStaleTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.staleTimeout = timeout
+		}
+	}
+}
+
+// WithAPITimeout sets how long a single Slack Web API call may take
+func WithAPITimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.apiTimeout = timeout
+		}
+	}
+}
+
+// apiContext bounds a Web API call by the configured timeout
+func (c *Client) apiContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, c.apiTimeout)
+}
@@ -0,0 +1,116 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// adminEmojiListURL lists the emojis of an Enterprise Grid organization with their uploaders
+const adminEmojiListURL = "https://slack.com/api/admin.emoji.list"
+
+// ListEmojis returns every custom emoji in the workspace mapped to its image URL or alias
+func (c *Client) ListEmojis(ctx context.Context) (map[string]string, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+
+	return c.api.GetEmojiContext(ctx)
+}
+
+// WithAdminToken looks up who uploaded each emoji with admin.emoji.list,
+// which needs an Enterprise Grid org admin user token with the
+// admin.teams:read scope. Without it, uploaders aren't known.
+func WithAdminToken(token string) ClientOption {
+	return func(c *Client) {
+		c.adminToken = token
+	}
+}
+
+// EmojiUploader returns the Slack user ID of whoever uploaded an emoji, or
+// nothing without an admin token or when Slack doesn't say. The uploaders
+// are listed again only for an emoji missing from the last list.
+func (c *Client) EmojiUploader(ctx context.Context, name string) (string, error) {
+	if c.adminToken == "" {
+		return "", nil
+	}
+
+	c.uploadersMu.Lock()
+	defer c.uploadersMu.Unlock()
+
+	if uploader, ok := c.uploaders[name]; ok {
+		return uploader, nil
+	}
+	uploaders, err := c.listEmojiUploaders(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list emoji uploaders: %w", err)
+	}
+	c.uploaders = uploaders
+	return uploaders[name], nil
+}
+
+// adminEmojiListResponse is a page of admin.emoji.list
+type adminEmojiListResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	Emoji map[string]struct {
+		UploadedBy string `json:"uploaded_by"`
+	} `json:"emoji"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+// listEmojiUploaders maps every emoji of the organization to its uploader
+func (c *Client) listEmojiUploaders(ctx context.Context) (map[string]string, error) {
+	uploaders := make(map[string]string)
+	cursor := ""
+	for {
+		page, err := c.adminEmojiListPage(ctx, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for name, emoji := range page.Emoji {
+			uploaders[name] = emoji.UploadedBy
+		}
+		if cursor = page.ResponseMetadata.NextCursor; cursor == "" {
+			return uploaders, nil
+		}
+	}
+}
+
+func (c *Client) adminEmojiListPage(ctx context.Context, cursor string) (*adminEmojiListResponse, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+
+	form := url.Values{"limit": {"1000"}}
+	if cursor != "" {
+		form.Set("cursor", cursor)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, adminEmojiListURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("admin.emoji.list returned %s", resp.Status)
+	}
+
+	var page adminEmojiListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+	if !page.OK {
+		return nil, fmt.Errorf("admin.emoji.list: %s", page.Error)
+	}
+	return &page, nil
+}
@@ -1,50 +1,243 @@
 package slack
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/rs/zerolog/log"
 	"github.com/slack-go/slack/socketmode"
 )
 
-// EventHandler is a function type for handling Slack events
-type EventHandler func(event interface{})
+const (
+	defaultQueueSize = 100
+
+	// slack expects envelopes to be acknowledged within 3 seconds
+	slowAckThreshold = 2 * time.Second
+)
+
+// EventHandler is a function type for handling Slack events. The context
+// outlives the listener's, so events it already accepted are handled in full
+// while the queue drains, and is canceled once Stop is done with the queue.
+type EventHandler func(ctx context.Context, event interface{})
+
+// AckStats summarizes how long envelopes took to be acknowledged
+type AckStats struct {
+	Count   int
+	Total   time.Duration
+	Max     time.Duration
+	Average time.Duration
+}
+
+type ackTracker struct {
+	mu    sync.Mutex
+	stats AckStats
+}
+
+func (t *ackTracker) record(latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-// ListenForEvents starts listening for Slack events
-func (c *Client) ListenForEvents() error {
-	c.stopChan = make(chan struct{})
+	t.stats.Count++
+	t.stats.Total += latency
+	if latency > t.stats.Max {
+		t.stats.Max = latency
+	}
+	t.stats.Average = t.stats.Total / time.Duration(t.stats.Count)
+}
+
+func (t *ackTracker) snapshot() AckStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// ListenForEvents starts listening for Slack events until ctx is done or Stop is called
+func (c *Client) ListenForEvents(ctx context.Context) error {
+	if c.socketClient == nil && c.httpEvents == nil {
+		return errors.New("slack socket mode client or HTTP events endpoint must be provided to listen for events")
+	}
+
+	c.handlerCtx, c.cancelHandler = context.WithCancel(context.WithoutCancel(ctx))
+	ctx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	if c.queueSize <= 0 {
+		c.queueSize = defaultQueueSize
+	}
+	c.queue = make(chan interface{}, c.queueSize)
+	c.drained = make(chan struct{})
 
 	go func() {
-		for {
-			select {
-			case <-c.stopChan:
-				return
-			default:
-				if err := c.socketClient.Run(); err != nil {
-					log.Error().Err(err).Msg("failed to run socket client")
-					return
-				}
+		defer close(c.drained)
+		for evt := range c.queue {
+			// past the shutdown deadline, the rest of the queue is lost
+			if c.handlerCtx.Err() != nil {
+				log.Warn().Str("event", fmt.Sprintf("%T", evt)).Msg("shutdown deadline passed, dropping queued event")
+				continue
 			}
+			c.handleEvent(evt)
 		}
 	}()
 
+	if c.httpEvents != nil {
+		return c.serveHTTPEvents(ctx)
+	}
+
+	go c.supervise(ctx)
+
 	go func() {
-		for evt := range c.socketClient.Events {
-			c.handleEvent(evt)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt := <-c.socketClient.Events:
+				c.observeState(evt)
+				c.acceptEvent(evt, time.Now())
+			}
 		}
 	}()
 
 	return nil
 }
 
+// enqueue hands an event to the processing queue, waiting for room if it is
+// full. It reports false once the listener has stopped accepting events.
+func (c *Client) enqueue(evt interface{}) bool {
+	c.queueMu.RLock()
+	defer c.queueMu.RUnlock()
+
+	if c.queueClosed {
+		return false
+	}
+
+	select {
+	case c.queue <- evt:
+	default:
+		log.Warn().Int("queue_size", cap(c.queue)).Msg("event queue is full, waiting for room")
+		c.queue <- evt
+	}
+	return true
+}
+
+// acceptEvent enqueues a socket mode event for processing and then acknowledges
+// its envelope. Events arriving during shutdown are left unacknowledged so
+// Slack redelivers them.
+func (c *Client) acceptEvent(evt socketmode.Event, receivedAt time.Time) {
+	if c.interactionHandler != nil && evt.Type == socketmode.EventTypeInteractive {
+		c.acceptInteraction(evt, receivedAt)
+		return
+	}
+
+	if !c.enqueue(evt) {
+		log.Debug().Str("type", string(evt.Type)).Msg("listener stopping, leaving event unacknowledged")
+		return
+	}
+	c.ack(evt, receivedAt)
+}
+
+// acceptInteraction acknowledges an interactive envelope and hands it to the
+// interaction handler right away instead of queueing it behind other events,
+// as a click's trigger expires within seconds. Stop waits for it.
+func (c *Client) acceptInteraction(evt socketmode.Event, receivedAt time.Time) {
+	c.queueMu.RLock()
+	defer c.queueMu.RUnlock()
+
+	if c.queueClosed {
+		log.Debug().Str("type", string(evt.Type)).Msg("listener stopping, leaving event unacknowledged")
+		return
+	}
+	c.ack(evt, receivedAt)
+	c.handleInteraction(evt)
+}
+
+// ack acknowledges a socket mode envelope and records how long it took
+func (c *Client) ack(evt socketmode.Event, receivedAt time.Time) {
+	if evt.Request == nil || evt.Request.EnvelopeID == "" {
+		return
+	}
+
+	if payload := ackPayload(evt); payload != nil {
+		c.socketClient.Ack(*evt.Request, payload)
+	} else {
+		c.socketClient.Ack(*evt.Request)
+	}
+
+	latency := time.Since(receivedAt)
+	c.acks.record(latency)
+
+	logger := log.With().
+		Str("envelope_id", evt.Request.EnvelopeID).
+		Str("type", string(evt.Type)).
+		Dur("latency", latency).
+		Logger()
+	if latency > slowAckThreshold {
+		logger.Warn().Msg("slow envelope acknowledgement")
+	} else {
+		logger.Debug().Msg("acknowledged envelope")
+	}
+}
+
+// ackPayload is the payload an envelope is acknowledged with. Interactive
+// envelopes are acknowledged without one, which closes a submitted modal, and
+// slash commands, which this app doesn't support, get an apology.
+func ackPayload(evt socketmode.Event) interface{} {
+	if evt.Type == socketmode.EventTypeSlashCommand {
+		return map[string]interface{}{
+			"response_type": "ephemeral",
+			"text":          "Sorry, slackmoji-notifier doesn't support slash commands.",
+		}
+	}
+	return nil
+}
+
 // handleEvent processes incoming Slack events
-func (c *Client) handleEvent(evt socketmode.Event) {
+func (c *Client) handleEvent(evt interface{}) {
+	if c.handleInteraction(evt) {
+		return
+	}
 	if c.eventHandler != nil {
-		c.eventHandler(evt)
+		c.eventHandler(c.handlerCtx, evt)
 	}
 }
 
-// Stop signals the event listener to stop
-func (c *Client) Stop() {
-	if c.stopChan != nil {
-		close(c.stopChan)
+// AckStats returns the envelope acknowledgement latency observed so far
+func (c *Client) AckStats() AckStats {
+	return c.acks.snapshot()
+}
+
+// Stop stops accepting events and returns once every event already queued
+// has been handed to the event handler, or once ctx is done. Events still
+// queued at that point are dropped and logged.
+func (c *Client) Stop(ctx context.Context) {
+	if c.cancel == nil {
+		return
+	}
+
+	// drain before canceling, so nothing already acknowledged is cut short
+	c.queueMu.Lock()
+	if !c.queueClosed {
+		c.queueClosed = true
+		log.Debug().Int("pending", len(c.queue)).Msg("draining event queue")
+		close(c.queue)
+	}
+	c.queueMu.Unlock()
+	select {
+	case <-c.drained:
+	case <-ctx.Done():
+		log.Warn().Int("pending", len(c.queue)).Msg("event queue not drained before the shutdown deadline")
+	}
+	c.cancel()
+	c.cancelHandler()
+
+	// only socket mode envelopes are acknowledged
+	if c.httpEvents == nil {
+		stats := c.AckStats()
+		log.Info().
+			Int("count", stats.Count).
+			Dur("average", stats.Average).
+			Dur("max", stats.Max).
+			Msg("envelope acknowledgement latency")
 	}
 }
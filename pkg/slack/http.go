@@ -0,0 +1,159 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// slack limits Events API payloads well below this
+const maxEventBodySize = 1 << 20
+
+// HTTPEvent is an Events API event received over the HTTP transport
+type HTTPEvent struct {
+	Event        slackevents.EventsAPIEvent
+	RetryAttempt int
+	RetryReason  string
+}
+
+type httpEventsConfig struct {
+	addr          string
+	path          string
+	signingSecret string
+	server        *http.Server
+}
+
+// WithHTTPEvents serves the Events API request URL on addr and path instead of
+// using Socket Mode. Requests are verified with the app's signing secret.
+func WithHTTPEvents(addr, path, signingSecret string) ClientOption {
+	return func(c *Client) {
+		c.httpEvents = &httpEventsConfig{
+			addr:          addr,
+			path:          path,
+			signingSecret: signingSecret,
+		}
+	}
+}
+
+// serveHTTPEvents starts the Events API HTTP server and returns once it is listening
+func (c *Client) serveHTTPEvents(ctx context.Context) error {
+	if c.httpEvents.signingSecret == "" {
+		return errors.New("signing secret must be provided to serve HTTP events")
+	}
+
+	c.setState(StateConnecting, "starting HTTP events server")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(c.httpEvents.path, c.handleHTTPEvent)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	c.httpEvents.server = server
+
+	listener, err := net.Listen("tcp", c.httpEvents.addr)
+	if err != nil {
+		c.setState(StateStopped, err.Error())
+		return err
+	}
+
+	go func() {
+		log.Info().Str("addr", listener.Addr().String()).Str("path", c.httpEvents.path).Msg("serving Slack Events API requests")
+		c.setState(StateConnected, "HTTP events server listening")
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("HTTP events server stopped")
+		}
+		c.setState(StateStopped, "HTTP events server stopped")
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Warn().Err(err).Msg("failed to shut down HTTP events server")
+		}
+	}()
+
+	return nil
+}
+
+// handleHTTPEvent verifies and accepts a single Events API request
+func (c *Client) handleHTTPEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventBodySize))
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to read Events API request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := verifyRequest(r.Header, body, c.httpEvents.signingSecret); err != nil {
+		log.Warn().Err(err).Str("remote", r.RemoteAddr).Msg("rejecting Events API request with invalid signature")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to parse Events API request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch event.Type {
+	case slackevents.URLVerification:
+		challenge, ok := event.Data.(*slackevents.EventsAPIURLVerificationEvent)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		log.Info().Msg("answering Events API URL verification challenge")
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, challenge.Challenge)
+
+	case slackevents.CallbackEvent:
+		retryAttempt, _ := strconv.Atoi(r.Header.Get("X-Slack-Retry-Num"))
+		accepted := c.enqueue(HTTPEvent{
+			Event:        event,
+			RetryAttempt: retryAttempt,
+			RetryReason:  r.Header.Get("X-Slack-Retry-Reason"),
+		})
+		if !accepted {
+			// slack retries failed deliveries, so let the next instance pick it up
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		log.Debug().Str("type", event.Type).Msg("ignoring unsupported Events API request")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// verifyRequest checks the X-Slack-Signature header against the signing secret
+func verifyRequest(header http.Header, body []byte, signingSecret string) error {
+	verifier, err := slack.NewSecretsVerifier(header, signingSecret)
+	if err != nil {
+		return err
+	}
+	if _, err := verifier.Write(body); err != nil {
+		return err
+	}
+	return verifier.Ensure()
+}
@@ -0,0 +1,212 @@
+package slack
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// Kinds of interaction passed to an InteractionHandler
+const (
+	InteractionBlockActions   = string(slack.InteractionTypeBlockActions)
+	InteractionViewSubmission = string(slack.InteractionTypeViewSubmission)
+)
+
+// Block Kit limits
+const (
+	maxButtonTextLength = 75
+	maxModalTitleLength = 24
+	maxInputLength      = 3000
+)
+
+// Button is shown at the bottom of a message and reported to the
+// InteractionHandler when clicked
+type Button struct {
+	ActionID string
+	Text     string
+	Value    string
+	// Danger styles the button red
+	Danger bool
+	// Confirm asks this question in a dialog before the click is reported
+	Confirm string
+}
+
+// Interaction is a button click or modal submission from Slack
+type Interaction struct {
+	// Type is InteractionBlockActions or InteractionViewSubmission
+	Type   string
+	UserID string
+	// TriggerID opens a modal in response, for a few seconds after the click
+	TriggerID string
+
+	// ActionID and Value identify the button clicked, and Message the message
+	// it was clicked on
+	ActionID string
+	Value    string
+	Message  MessageRef
+
+	// CallbackID and Metadata are those of the submitted modal, and Inputs
+	// its input values by action ID
+	CallbackID string
+	Metadata   string
+	Inputs     map[string]string
+}
+
+// InteractionHandler handles button clicks and modal submissions. Its context
+// is the same as the EventHandler's.
+type InteractionHandler func(ctx context.Context, in Interaction)
+
+// Modal is a dialog with a single text input
+type Modal struct {
+	// CallbackID and Metadata are passed back with the submission
+	CallbackID string
+	Metadata   string
+	Title      string
+	Submit     string
+	Label      string
+	// InputID is the action ID the input's value is submitted under
+	InputID   string
+	Value     string
+	Multiline bool
+}
+
+// WithInteractionHandler routes button clicks and modal submissions to
+// handler instead of the event handler. They only arrive over Socket Mode,
+// and are handled as soon as they are acknowledged rather than queued behind
+// other events, so handler must return quickly.
+func WithInteractionHandler(handler InteractionHandler) ClientOption {
+	return func(c *Client) {
+		c.interactionHandler = handler
+	}
+}
+
+// handleInteraction passes an interactive envelope to the interaction
+// handler, reporting false if it isn't one the handler takes
+func (c *Client) handleInteraction(evt interface{}) bool {
+	if c.interactionHandler == nil {
+		return false
+	}
+	socketEvent, ok := evt.(socketmode.Event)
+	if !ok || socketEvent.Type != socketmode.EventTypeInteractive {
+		return false
+	}
+	callback, ok := socketEvent.Data.(slack.InteractionCallback)
+	if !ok {
+		log.Debug().Msg("interactive event data is not an interaction callback")
+		return true
+	}
+
+	in, ok := parseInteraction(callback)
+	if !ok {
+		log.Debug().Str("type", string(callback.Type)).Msg("ignoring unsupported interaction")
+		return true
+	}
+	c.interactionHandler(c.handlerCtx, in)
+	return true
+}
+
+// parseInteraction extracts what handlers need from an interaction callback
+func parseInteraction(callback slack.InteractionCallback) (Interaction, bool) {
+	in := Interaction{
+		Type:      string(callback.Type),
+		UserID:    callback.User.ID,
+		TriggerID: callback.TriggerID,
+	}
+
+	switch callback.Type {
+	case slack.InteractionTypeBlockActions:
+		if len(callback.ActionCallback.BlockActions) == 0 {
+			return Interaction{}, false
+		}
+		action := callback.ActionCallback.BlockActions[0]
+		in.ActionID, in.Value = action.ActionID, action.Value
+		in.Message = MessageRef{Channel: callback.Container.ChannelID, Timestamp: callback.Container.MessageTs}
+		if in.Message.Channel == "" {
+			in.Message = MessageRef{Channel: callback.Channel.ID, Timestamp: callback.Message.Timestamp}
+		}
+
+	case slack.InteractionTypeViewSubmission:
+		in.CallbackID, in.Metadata = callback.View.CallbackID, callback.View.PrivateMetadata
+		in.Inputs = make(map[string]string)
+		if callback.View.State != nil {
+			for _, block := range callback.View.State.Values {
+				for actionID, action := range block {
+					in.Inputs[actionID] = action.Value
+				}
+			}
+		}
+
+	default:
+		return Interaction{}, false
+	}
+	return in, true
+}
+
+// buttonsBlock lays buttons out side by side in an actions block
+func buttonsBlock(buttons []Button) slack.Block {
+	elements := make([]slack.BlockElement, 0, len(buttons))
+	for _, button := range buttons {
+		text := slack.NewTextBlockObject(slack.PlainTextType, truncate(button.Text, maxButtonTextLength), true, false)
+		element := slack.NewButtonBlockElement(button.ActionID, button.Value, text)
+		if button.Danger {
+			element = element.WithStyle(slack.StyleDanger)
+		}
+		if button.Confirm != "" {
+			element = element.WithConfirm(slack.NewConfirmationBlockObject(
+				slack.NewTextBlockObject(slack.PlainTextType, "Are you sure?", false, false),
+				slack.NewTextBlockObject(slack.PlainTextType, button.Confirm, false, false),
+				slack.NewTextBlockObject(slack.PlainTextType, truncate(button.Text, maxButtonTextLength), false, false),
+				slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+			))
+		}
+		elements = append(elements, element)
+	}
+	return slack.NewActionBlock("", elements...)
+}
+
+// OpenModal opens a modal for the user whose click produced triggerID
+func (c *Client) OpenModal(ctx context.Context, triggerID string, modal Modal) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+
+	input := slack.NewPlainTextInputBlockElement(nil, modal.InputID).WithMaxLength(maxInputLength)
+	if modal.Value != "" {
+		input = input.WithInitialValue(modal.Value)
+	}
+	if modal.Multiline {
+		input = input.WithMultiline(true)
+	}
+	label := slack.NewTextBlockObject(slack.PlainTextType, modal.Label, false, false)
+
+	view := slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, truncate(modal.Title, maxModalTitleLength), false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, modal.Submit, false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		Blocks:          slack.Blocks{BlockSet: []slack.Block{slack.NewInputBlock("", label, nil, input)}},
+		CallbackID:      modal.CallbackID,
+		PrivateMetadata: modal.Metadata,
+	}
+	_, err := c.api.OpenViewContext(ctx, triggerID, view)
+	return err
+}
+
+// DeleteMessage deletes a previously posted message
+func (c *Client) DeleteMessage(ctx context.Context, ref MessageRef) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+
+	_, _, err := c.api.DeleteMessageContext(ctx, ref.Channel, ref.Timestamp)
+	return err
+}
+
+// PostEphemeral posts a message only userID can see in a channel
+func (c *Client) PostEphemeral(ctx context.Context, channel, userID, text string) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+
+	_, err := c.api.PostEphemeralContext(ctx, channel, userID, slack.MsgOptionText(text, false))
+	return err
+}
@@ -1,8 +1,19 @@
 package slack
 
+import "context"
+
 // ClientInterface is an interface for the Slack client
 type ClientInterface interface {
-	ListenForEvents() error
-	SendMessage(content MessageContent) error
-	Stop()
+	ListenForEvents(ctx context.Context) error
+	SendMessage(ctx context.Context, content MessageContent) (MessageRef, error)
+	ReplyInThread(ctx context.Context, parent MessageRef, content MessageContent) (MessageRef, error)
+	UpdateMessage(ctx context.Context, ref MessageRef, content MessageContent) error
+	Permalink(ctx context.Context, ref MessageRef) (string, error)
+	DeleteMessage(ctx context.Context, ref MessageRef) error
+	PostEphemeral(ctx context.Context, channel, userID, text string) error
+	OpenModal(ctx context.Context, triggerID string, modal Modal) error
+	ListEmojis(ctx context.Context) (map[string]string, error)
+	EmojiUploader(ctx context.Context, name string) (string, error)
+	ConnectionState() ConnectionState
+	Stop(ctx context.Context)
 }
@@ -1,30 +1,98 @@
 package slack
 
 import (
+	"context"
+
 	"github.com/slack-go/slack"
 )
 
-// Attachment represents an attachment to a Slack message
-type Attachment struct {
-	ImageURL string
-	Text     string
+// Image is an image shown in a Slack message
+type Image struct {
+	URL string
+	// AltText describes the image to screen readers and where it fails to load
+	AltText string
+	// Title is shown above the image when set
+	Title string
 }
 
-// MessageContent represents the content of a Slack message
+// MessageContent represents the content of a Slack message. It is posted as
+// Block Kit blocks, in order: a header, a mrkdwn body, images, a line of
+// small mrkdwn context and buttons, each left out when empty.
 type MessageContent struct {
-	Text        string
-	Attachments []Attachment
+	// Channel overrides the client's default channel when set
+	Channel string
+	// Text is the fallback shown in notifications and by clients that can't
+	// render blocks. The header or body is used when it is empty.
+	Text   string
+	Header string
+	Body   string
+	Images []Image
+	// Grid shows small images with their titles in rows, like a grid of emojis
+	Grid    []Image
+	Context []string
+	Buttons []Button
+}
+
+// MessageRef identifies a message that was posted to Slack
+type MessageRef struct {
+	Channel   string
+	Timestamp string
 }
 
 // SendMessage sends a message to the specified Slack channel
-func (c *Client) SendMessage(content MessageContent) error {
-	_, _, err := c.api.PostMessage(
-		c.channel,
-		slack.MsgOptionText(content.Text, false),
-		slack.MsgOptionAttachments(slack.Attachment{
-			ImageURL: content.Attachments[0].ImageURL,
-			Text:     content.Attachments[0].Text,
-		}),
-	)
+func (c *Client) SendMessage(ctx context.Context, content MessageContent) (MessageRef, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+
+	channel := content.Channel
+	if channel == "" {
+		channel = c.channel
+	}
+
+	channelID, ts, err := c.api.PostMessageContext(ctx, channel, messageOptions(content)...)
+	if err != nil {
+		return MessageRef{}, err
+	}
+	return MessageRef{Channel: channelID, Timestamp: ts}, nil
+}
+
+// ReplyInThread posts a message as a reply in the thread of a previously posted message
+func (c *Client) ReplyInThread(ctx context.Context, parent MessageRef, content MessageContent) (MessageRef, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+
+	options := append(messageOptions(content), slack.MsgOptionTS(parent.Timestamp))
+	channelID, ts, err := c.api.PostMessageContext(ctx, parent.Channel, options...)
+	if err != nil {
+		return MessageRef{}, err
+	}
+	return MessageRef{Channel: channelID, Timestamp: ts}, nil
+}
+
+// UpdateMessage replaces the content of a previously posted message
+func (c *Client) UpdateMessage(ctx context.Context, ref MessageRef, content MessageContent) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+
+	_, _, _, err := c.api.UpdateMessageContext(ctx, ref.Channel, ref.Timestamp, messageOptions(content)...)
 	return err
 }
+
+// Permalink returns a link to a previously posted message
+func (c *Client) Permalink(ctx context.Context, ref MessageRef) (string, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+
+	return c.api.GetPermalinkContext(ctx, &slack.PermalinkParameters{Channel: ref.Channel, Ts: ref.Timestamp})
+}
+
+// messageOptions converts message content into chat.postMessage/chat.update options
+func messageOptions(content MessageContent) []slack.MsgOption {
+	return []slack.MsgOption{
+		slack.MsgOptionText(content.Fallback(), false),
+		// always set blocks so an update replaces the ones previously posted,
+		// and clear the legacy attachments of messages posted by older versions
+		slack.MsgOptionBlocks(content.Blocks()...),
+		slack.MsgOptionAttachments([]slack.Attachment{}...),
+	}
+}
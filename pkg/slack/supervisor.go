@@ -0,0 +1,167 @@
+package slack
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	defaultBackoffBase  = 1 * time.Second
+	defaultBackoffMax   = 2 * time.Minute
+	defaultStaleTimeout = 5 * time.Minute
+
+	// a connection that stayed up this long resets the backoff
+	stableConnection = 1 * time.Minute
+)
+
+// ConnectionState describes the health of the connection to Slack
+type ConnectionState string
+
+const (
+	StateConnecting ConnectionState = "connecting"
+	StateConnected  ConnectionState = "connected"
+	StateDegraded   ConnectionState = "degraded"
+	StateStopped    ConnectionState = "stopped"
+)
+
+// ConnectionState returns the current state of the connection to Slack
+func (c *Client) ConnectionState() ConnectionState {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	if c.state == "" {
+		return StateStopped
+	}
+	return c.state
+}
+
+// setState records a state transition and logs it
+func (c *Client) setState(state ConnectionState, reason string) {
+	c.stateMu.Lock()
+	previous := c.state
+	if previous == state {
+		c.stateMu.Unlock()
+		return
+	}
+	c.state = state
+	c.stateSince = time.Now()
+	c.stateMu.Unlock()
+
+	logger := log.With().
+		Str("from", string(previous)).
+		Str("to", string(state)).
+		Str("reason", reason).
+		Logger()
+	if state == StateDegraded {
+		logger.Warn().Msg("Slack connection state changed")
+	} else {
+		logger.Info().Msg("Slack connection state changed")
+	}
+}
+
+// stateAge returns how long the connection has been in its current state
+func (c *Client) stateAge() time.Duration {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return time.Since(c.stateSince)
+}
+
+// observeState tracks the connection state from socket mode lifecycle events
+func (c *Client) observeState(evt socketmode.Event) {
+	switch evt.Type {
+	case socketmode.EventTypeConnecting:
+		c.setState(StateConnecting, string(evt.Type))
+	case socketmode.EventTypeConnected, socketmode.EventTypeHello:
+		c.setState(StateConnected, string(evt.Type))
+	case socketmode.EventTypeConnectionError, socketmode.EventTypeInvalidAuth,
+		socketmode.EventTypeIncomingError, socketmode.EventTypeDisconnect:
+		c.setState(StateDegraded, string(evt.Type))
+	}
+}
+
+// supervise runs the socket mode client until ctx is done, restarting it with
+// exponential backoff and jitter whenever it fails or goes stale
+func (c *Client) supervise(ctx context.Context) {
+	defer c.setState(StateStopped, "listener stopped")
+
+	attempt := 0
+	for {
+		c.setState(StateConnecting, "starting socket client")
+
+		runCtx, cancel := context.WithCancel(ctx)
+		go c.watchdog(runCtx, cancel)
+
+		started := time.Now()
+		err := c.socketClient.RunContext(runCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > stableConnection {
+			attempt = 0
+		}
+
+		reason := "socket client exited"
+		if err != nil {
+			reason = err.Error()
+		}
+		c.setState(StateDegraded, reason)
+
+		delay := c.backoff(attempt)
+		attempt++
+		log.Warn().Err(err).Int("attempt", attempt).Dur("retry_in", delay).Msg("socket client stopped, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// watchdog restarts the connection when it hasn't been connected for longer
+// than the stale timeout. Silent but connected sockets are detected by the
+// socket mode client's own ping deadline.
+func (c *Client) watchdog(ctx context.Context, restart context.CancelFunc) {
+	interval := c.staleTimeout / 4
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if c.ConnectionState() == StateConnected {
+				continue
+			}
+			if age := c.stateAge(); age > c.staleTimeout {
+				log.Warn().
+					Str("state", string(c.ConnectionState())).
+					Dur("age", age).
+					Msg("Slack connection is stale, restarting")
+				restart()
+				return
+			}
+		}
+	}
+}
+
+// backoff returns the delay before the given reconnect attempt, with jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.backoffMax
+	if attempt < 32 {
+		if d := c.backoffBase << attempt; d > 0 && d < c.backoffMax {
+			delay = d
+		}
+	}
+
+	// full jitter over the upper half keeps reconnects from synchronizing
+	half := delay / 2
+	return half + rand.N(half+1)
+}
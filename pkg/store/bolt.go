@@ -0,0 +1,162 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	boltLockTimeout = 2 * time.Second
+	// boltCopyAttempts bounds retries of a copy taken while the file was being written
+	boltCopyAttempts = 3
+)
+
+func init() {
+	Register("bolt", openBolt)
+	RegisterReadOnly("bolt", openBoltReadOnly)
+}
+
+// boltBackend persists state in a local embedded bbolt database file
+type boltBackend struct {
+	db *bolt.DB
+	// copyPath is a temporary copy of the database that is removed on close
+	copyPath string
+}
+
+func openBolt(path string) (Backend, error) {
+	// bbolt holds an exclusive file lock, so fail fast if another process has it open
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltLockTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%s is locked by another process, stop any running listen first: %w", path, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &boltBackend{db: db}, nil
+}
+
+func (b *boltBackend) Get(bucket, key string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		if v := bkt.Get([]byte(key)); v != nil {
+			// values are only valid for the life of the transaction
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return value, err
+}
+
+func (b *boltBackend) Put(bucket, key string, value []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return bkt.Put([]byte(key), value)
+	})
+}
+
+func (b *boltBackend) Delete(bucket, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.Delete([]byte(key))
+	})
+}
+
+func (b *boltBackend) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
+
+func (b *boltBackend) Close() error {
+	err := b.db.Close()
+	if b.copyPath != "" {
+		os.Remove(b.copyPath)
+	}
+	return err
+}
+
+// openBoltReadOnly opens the database for reading. A running listener holds
+// an exclusive lock that even readers wait for, so when the lock can't be had
+// a copy of the file is read instead.
+func openBoltReadOnly(path string) (Backend, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{ReadOnly: true, Timeout: boltLockTimeout})
+	if err == nil {
+		return &boltBackend{db: db}, nil
+	}
+	if !errors.Is(err, bolt.ErrTimeout) {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		backend, err := openBoltCopy(path)
+		if err == nil || attempt == boltCopyAttempts {
+			return backend, err
+		}
+	}
+}
+
+// openBoltCopy copies the database file and opens the copy, checking that it
+// wasn't torn by a write that happened while it was copied
+func openBoltCopy(path string) (Backend, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "slackmoji-notifier-*.db")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return nil, fmt.Errorf("failed to copy %s: %w", path, err)
+	}
+
+	db, err := bolt.Open(dst.Name(), 0o600, &bolt.Options{ReadOnly: true, Timeout: boltLockTimeout})
+	if err == nil {
+		err = db.View(func(tx *bolt.Tx) error {
+			var torn error
+			// drain every error so the check is done before the transaction ends
+			for err := range tx.Check() {
+				if torn == nil {
+					torn = err
+				}
+			}
+			return torn
+		})
+		if err != nil {
+			db.Close()
+		}
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return nil, fmt.Errorf("failed to read a copy of %s, which is locked by another process: %w", path, err)
+	}
+	return &boltBackend{db: db, copyPath: dst.Name()}, nil
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Dump is the portable representation of the whole store used for backups
+type Dump struct {
+	SchemaVersion int                                   `json:"schema_version"`
+	ExportedAt    time.Time                             `json:"exported_at"`
+	Buckets       map[string]map[string]json.RawMessage `json:"buckets"`
+}
+
+// Export writes every bucket of the store to w as JSON
+func (s *Store) Export(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	dump := Dump{
+		SchemaVersion: version,
+		ExportedAt:    time.Now().UTC(),
+		Buckets:       make(map[string]map[string]json.RawMessage, len(buckets)),
+	}
+
+	for _, bucket := range buckets {
+		records := make(map[string]json.RawMessage)
+		err := s.backend.ForEach(bucket, func(key string, value []byte) error {
+			if portable(bucket, key) {
+				records[key] = append(json.RawMessage(nil), value...)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export bucket %s: %w", bucket, err)
+		}
+		dump.Buckets[bucket] = records
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dump)
+}
+
+// Import loads a dump produced by Export into the store. Existing records are
+// overwritten, and every other record is removed first when replace is set.
+// Dumps from older schema versions are migrated after loading.
+func (s *Store) Import(r io.Reader, replace bool) (int, error) {
+	var dump Dump
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return 0, fmt.Errorf("failed to decode state dump: %w", err)
+	}
+	if dump.SchemaVersion > latestSchemaVersion() {
+		return 0, fmt.Errorf("state dump schema version %d is newer than supported version %d", dump.SchemaVersion, latestSchemaVersion())
+	}
+
+	known := make(map[string]bool, len(buckets))
+	for _, bucket := range buckets {
+		known[bucket] = true
+	}
+	for bucket := range dump.Buckets {
+		if !known[bucket] {
+			return 0, fmt.Errorf("state dump contains unknown bucket %q", bucket)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if replace {
+		for _, bucket := range buckets {
+			if err := s.clear(bucket); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	count := 0
+	for bucket, records := range dump.Buckets {
+		for key, value := range records {
+			if !portable(bucket, key) {
+				continue
+			}
+			if err := s.backend.Put(bucket, key, value); err != nil {
+				return count, fmt.Errorf("failed to import %s/%s: %w", bucket, key, err)
+			}
+			count++
+		}
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return count, err
+	}
+	if dump.SchemaVersion < current {
+		if err := s.setSchemaVersion(dump.SchemaVersion); err != nil {
+			return count, err
+		}
+		if err := s.migrate(); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// portable reports whether a record moves between stores with Export and
+// Import. The schema version belongs to the store itself; a dump records its
+// own, and Import migrates from it.
+func portable(bucket, key string) bool {
+	return bucket != bucketMeta || key != schemaVersionKey
+}
+
+// clear removes every portable record from a bucket
+func (s *Store) clear(bucket string) error {
+	var keys []string
+	err := s.backend.ForEach(bucket, func(key string, _ []byte) error {
+		if portable(bucket, key) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := s.backend.Delete(bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
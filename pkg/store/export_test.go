@@ -0,0 +1,77 @@
+package store
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestExportImport checks that a dump restores every kind of record,
+// including the bookkeeping kept in the meta bucket, without touching the
+// schema version of the store it is imported into
+func TestExportImport(t *testing.T) {
+	at := time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+	thread := &DailyThread{Day: "2026-03-01", Channel: "C1", Timestamp: "1.2", Names: []string{"cat"}}
+	designThread := &DailyThread{Destination: "#design", Day: "2026-03-01", Channel: "C2", Timestamp: "3.4"}
+	emoji := &Emoji{Name: "cat", URL: "https://example.com/cat.png", Active: true, AddedAt: at}
+
+	src := openMemory(t)
+	for _, err := range []error{
+		src.SetBaselineAt(at),
+		src.PutLastRoundup(at.Add(time.Hour)),
+		src.PutDailyThread(thread),
+		src.PutDailyThread(designThread),
+		src.PutEmoji(emoji),
+	} {
+		if err != nil {
+			t.Fatalf("filling the store: %v", err)
+		}
+	}
+
+	var dump bytes.Buffer
+	if err := src.Export(&dump); err != nil {
+		t.Fatalf("exporting: %v", err)
+	}
+
+	for _, replace := range []bool{false, true} {
+		dst := openMemory(t)
+		if err := dst.PutEmoji(&Emoji{Name: "dog", Active: true}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dst.Import(bytes.NewReader(dump.Bytes()), replace); err != nil {
+			t.Fatalf("importing with replace %v: %v", replace, err)
+		}
+
+		if got, err := dst.BaselineAt(); err != nil || !got.Equal(at) {
+			t.Errorf("baseline = %v, %v, want %v", got, err, at)
+		}
+		if got, err := dst.LastRoundup(); err != nil || !got.Equal(at.Add(time.Hour)) {
+			t.Errorf("last roundup = %v, %v, want %v", got, err, at.Add(time.Hour))
+		}
+		for _, want := range []*DailyThread{thread, designThread} {
+			if got, err := dst.DailyThread(want.Destination); err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("daily thread for %q = %+v, %v, want %+v", want.Destination, got, err, want)
+			}
+		}
+		if got, err := dst.GetEmoji("cat"); err != nil || !got.AddedAt.Equal(at) || got.URL != emoji.URL {
+			t.Errorf("emoji = %+v, %v, want %+v", got, err, emoji)
+		}
+		if _, err := dst.GetEmoji("dog"); (err == nil) == replace {
+			t.Errorf("existing emoji with replace %v: %v", replace, err)
+		}
+		if version, err := dst.SchemaVersion(); err != nil || version != latestSchemaVersion() {
+			t.Errorf("schema version = %d, %v, want %d", version, err, latestSchemaVersion())
+		}
+	}
+}
+
+func openMemory(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open("memory", "")
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// HeldAnnouncement is an announcement waiting for its channel's quiet hours
+// to end. Key orders held announcements by when they were held.
+type HeldAnnouncement struct {
+	OutboxEntry
+	Key     string    `json:"key"`
+	Channel string    `json:"channel,omitempty"`
+	Due     time.Time `json:"due"`
+}
+
+// PutHeld holds an announcement until it is due. A new announcement is keyed
+// after every one held before it.
+func (s *Store) PutHeld(h *HeldAnnouncement) error {
+	if h.Key == "" {
+		h.Key = fmt.Sprintf("%020d:%s", h.CreatedAt.UnixNano(), h.ID)
+	}
+	return s.put(bucketHeld, h.Key, h)
+}
+
+// DeleteHeld forgets a held announcement
+func (s *Store) DeleteHeld(key string) error {
+	return s.backend.Delete(bucketHeld, key)
+}
+
+// ListHeld returns every held announcement in the order they were held
+func (s *Store) ListHeld() ([]*HeldAnnouncement, error) {
+	var held []*HeldAnnouncement
+	err := s.backend.ForEach(bucketHeld, func(_ string, value []byte) error {
+		var h HeldAnnouncement
+		if err := json.Unmarshal(value, &h); err != nil {
+			return err
+		}
+		held = append(held, &h)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(held, func(i, j int) bool { return held[i].Key < held[j].Key })
+	return held, nil
+}
+
+// renameHeld points held announcements for oldName at newName
+func (s *Store) renameHeld(oldName, newName string) error {
+	var changed []*HeldAnnouncement
+	err := s.backend.ForEach(bucketHeld, func(_ string, value []byte) error {
+		var h HeldAnnouncement
+		if err := json.Unmarshal(value, &h); err != nil {
+			return err
+		}
+		i := slices.Index(h.Emojis, oldName)
+		if h.Emoji != oldName && i < 0 {
+			return nil
+		}
+		if h.Emoji == oldName {
+			h.Emoji = newName
+		}
+		if i >= 0 {
+			h.Emojis[i] = newName
+		}
+		changed = append(changed, &h)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, h := range changed {
+		if err := s.put(bucketHeld, h.Key, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+// HistoryType is the kind of change recorded in an emoji's history
+type HistoryType string
+
+const (
+	HistoryAdded       HistoryType = "added"
+	HistoryAlias       HistoryType = "alias"
+	HistoryReplaced    HistoryType = "replaced"
+	HistoryReturned    HistoryType = "returned"
+	HistoryRemoved     HistoryType = "removed"
+	HistoryRenamed     HistoryType = "renamed"
+	HistoryAnnounced   HistoryType = "announced"
+	HistoryUndelivered HistoryType = "undelivered"
+	HistoryEdited      HistoryType = "edited"
+	HistoryDeleted     HistoryType = "deleted"
+)
+
+// HistoryEvent is a single entry in an emoji's timeline
+type HistoryEvent struct {
+	At           time.Time   `json:"at"`
+	Type         HistoryType `json:"type"`
+	Emoji        string      `json:"emoji"`
+	PreviousName string      `json:"previous_name,omitempty"`
+	AliasOf      string      `json:"alias_of,omitempty"`
+	URL          string      `json:"url,omitempty"`
+
+	// announcement details
+	Kind      string      `json:"kind,omitempty"`
+	Text      string      `json:"text,omitempty"`
+	Model     string      `json:"model,omitempty"`
+	Message   *MessageRef `json:"message,omitempty"`
+	Permalink string      `json:"permalink,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	// User is who edited or deleted an announcement
+	User string `json:"user,omitempty"`
+}
+
+// AppendHistory adds an event to the timeline of the named emoji
+func (s *Store) AppendHistory(name string, e HistoryEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, err := s.history(name)
+	if err != nil {
+		return err
+	}
+	return s.put(bucketHistory, name, append(events, e))
+}
+
+// History returns the timeline of the named emoji, oldest first. Renamed
+// emojis keep the history of their earlier names.
+func (s *Store) History(name string) ([]HistoryEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.history(name)
+}
+
+// HistoryBetween returns the events recorded at or after from and before to,
+// oldest first. Events of renamed emojis are keyed by their current name.
+func (s *Store) HistoryBetween(from, to time.Time) (map[string][]HistoryEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	period := make(map[string][]HistoryEvent)
+	err := s.backend.ForEach(bucketHistory, func(name string, value []byte) error {
+		var events []HistoryEvent
+		if err := json.Unmarshal(value, &events); err != nil {
+			return err
+		}
+		for _, e := range events {
+			if !e.At.Before(from) && e.At.Before(to) {
+				period[name] = append(period[name], e)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+func (s *Store) history(name string) ([]HistoryEvent, error) {
+	var events []HistoryEvent
+	if err := s.get(bucketHistory, name, &events); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	return events, nil
+}
+
+// renameHistory moves the timeline of oldName onto newName, merging it with
+// any history newName already had
+func (s *Store) renameHistory(oldName, newName string) error {
+	moved, err := s.history(oldName)
+	if err != nil || len(moved) == 0 {
+		return err
+	}
+	existing, err := s.history(newName)
+	if err != nil {
+		return err
+	}
+
+	events := append(existing, moved...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	if err := s.put(bucketHistory, newName, events); err != nil {
+		return err
+	}
+	return s.backend.Delete(bucketHistory, oldName)
+}
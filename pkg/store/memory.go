@@ -0,0 +1,78 @@
+package store
+
+import (
+	"sort"
+	"sync"
+)
+
+func init() {
+	Register("memory", func(string) (Backend, error) {
+		return newMemoryBackend(), nil
+	})
+}
+
+// memoryBackend keeps state in process memory, which is lost on restart
+type memoryBackend struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{buckets: make(map[string]map[string][]byte)}
+}
+
+func (m *memoryBackend) Get(bucket, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	value, ok := m.buckets[bucket][key]
+	if !ok {
+		return nil, nil
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (m *memoryBackend) Put(bucket, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bkt, ok := m.buckets[bucket]
+	if !ok {
+		bkt = make(map[string][]byte)
+		m.buckets[bucket] = bkt
+	}
+	bkt[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (m *memoryBackend) Delete(bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+func (m *memoryBackend) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	// copy out under the lock so fn may call back into the backend
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.buckets[bucket]))
+	values := make(map[string][]byte, len(m.buckets[bucket]))
+	for k, v := range m.buckets[bucket] {
+		keys = append(keys, k)
+		values[k] = v
+	}
+	m.mu.RUnlock()
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+const schemaVersionKey = "schema_version"
+
+// migration upgrades the stored data from version-1 to version
+type migration struct {
+	version     int
+	description string
+	up          func(b Backend) error
+}
+
+// migrations must stay ordered by version and never be edited once released
+var migrations = []migration{
+	{
+		version:     1,
+		description: "initial schema",
+		up:          func(Backend) error { return nil },
+	},
+}
+
+// latestSchemaVersion is the schema version written by this build
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the schema version the stored data is currently at
+func (s *Store) SchemaVersion() (int, error) {
+	var version int
+	err := s.get(bucketMeta, schemaVersionKey, &version)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	return version, err
+}
+
+func (s *Store) setSchemaVersion(version int) error {
+	return s.put(bucketMeta, schemaVersionKey, version)
+}
+
+// migrate applies every migration newer than the stored schema version
+func (s *Store) migrate() error {
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > latestSchemaVersion() {
+		return fmt.Errorf("state schema version %d is newer than supported version %d", current, latestSchemaVersion())
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		log.Info().Int("version", m.version).Str("description", m.description).Msg("applying state migration")
+		if err := m.up(s.backend); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+		if err := s.setSchemaVersion(m.version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
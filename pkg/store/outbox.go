@@ -0,0 +1,126 @@
+package store
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OutboxEntry is a notification that could not be delivered after exhausting its retries
+type OutboxEntry struct {
+	ID           string    `json:"id"`
+	Kind         string    `json:"kind,omitempty"`
+	Emoji        string    `json:"emoji"`
+	Emojis       []string  `json:"emojis,omitempty"`
+	Pack         string    `json:"pack,omitempty"`
+	PreviousName string    `json:"previous_name,omitempty"`
+	PreviousURL  string    `json:"previous_url,omitempty"`
+	URL          string    `json:"url,omitempty"`
+	Sentence     string    `json:"sentence,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	Destination  string    `json:"destination,omitempty"`
+	Rule         string    `json:"rule,omitempty"`
+	Stage        string    `json:"stage"`
+	Class        string    `json:"class"`
+	Error        string    `json:"error"`
+	Attempts     int       `json:"attempts"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// GetOutbox returns a single outbox entry, or ErrNotFound
+func (s *Store) GetOutbox(id string) (*OutboxEntry, error) {
+	var e OutboxEntry
+	if err := s.get(bucketOutbox, id, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// PutOutbox creates or replaces an outbox entry
+func (s *Store) PutOutbox(e *OutboxEntry) error {
+	return s.put(bucketOutbox, e.ID, e)
+}
+
+// DeleteOutbox removes an outbox entry
+func (s *Store) DeleteOutbox(id string) error {
+	return s.backend.Delete(bucketOutbox, id)
+}
+
+// ListOutbox returns every outbox entry, oldest first
+func (s *Store) ListOutbox() ([]*OutboxEntry, error) {
+	var entries []*OutboxEntry
+	err := s.backend.ForEach(bucketOutbox, func(_ string, value []byte) error {
+		var e OutboxEntry
+		if err := json.Unmarshal(value, &e); err != nil {
+			return err
+		}
+		entries = append(entries, &e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].ID < entries[j].ID
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+// renameOutbox moves undelivered announcements for oldName to newName
+func (s *Store) renameOutbox(oldName, newName string) error {
+	var moved, updated []*OutboxEntry
+	err := s.backend.ForEach(bucketOutbox, func(_ string, value []byte) error {
+		var e OutboxEntry
+		if err := json.Unmarshal(value, &e); err != nil {
+			return err
+		}
+		if e.Emoji == oldName {
+			moved = append(moved, &e)
+			return nil
+		}
+		// digests are keyed by their first emoji, so only their lists change
+		if i := slices.Index(e.Emojis, oldName); i >= 0 {
+			e.Emojis[i] = newName
+			updated = append(updated, &e)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range updated {
+		if err := s.put(bucketOutbox, e.ID, e); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range moved {
+		if err := s.backend.Delete(bucketOutbox, e.ID); err != nil {
+			return err
+		}
+		if prefix, _, ok := strings.Cut(e.ID, ":"); ok && prefix == e.Kind {
+			e.ID = e.Kind + ":" + newName
+		} else {
+			e.ID = newName
+		}
+		if e.Destination != "" {
+			e.ID += "@" + e.Destination
+		}
+		e.Emoji = newName
+		if i := slices.Index(e.Emojis, oldName); i >= 0 {
+			e.Emojis[i] = newName
+		}
+		if err := s.put(bucketOutbox, e.ID, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// PostedMessage is an announcement posted with buttons, kept so it can be
+// rebuilt when someone clicks one of them
+type PostedMessage struct {
+	OutboxEntry
+	Channel   string    `json:"channel"`
+	Timestamp string    `json:"ts"`
+	PostedAt  time.Time `json:"posted_at"`
+}
+
+func postedKey(channel, ts string) string {
+	return channel + ":" + ts
+}
+
+// GetPosted returns the announcement posted as a message, or ErrNotFound
+func (s *Store) GetPosted(channel, ts string) (*PostedMessage, error) {
+	var p PostedMessage
+	if err := s.get(bucketPosted, postedKey(channel, ts), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// PutPosted creates or replaces a posted announcement
+func (s *Store) PutPosted(p *PostedMessage) error {
+	return s.put(bucketPosted, postedKey(p.Channel, p.Timestamp), p)
+}
+
+// DeletePosted forgets a posted announcement
+func (s *Store) DeletePosted(channel, ts string) error {
+	return s.backend.Delete(bucketPosted, postedKey(channel, ts))
+}
+
+// PrunePosted forgets announcements posted before the given time and returns
+// how many were removed
+func (s *Store) PrunePosted(before time.Time) (int, error) {
+	var expired []string
+	err := s.backend.ForEach(bucketPosted, func(key string, value []byte) error {
+		var p PostedMessage
+		if err := json.Unmarshal(value, &p); err != nil {
+			return err
+		}
+		if p.PostedAt.Before(before) {
+			expired = append(expired, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range expired {
+		if err := s.backend.Delete(bucketPosted, key); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+// renamePosted points posted announcements for oldName at newName
+func (s *Store) renamePosted(oldName, newName string) error {
+	var changed []*PostedMessage
+	err := s.backend.ForEach(bucketPosted, func(_ string, value []byte) error {
+		var p PostedMessage
+		if err := json.Unmarshal(value, &p); err != nil {
+			return err
+		}
+		i := slices.Index(p.Emojis, oldName)
+		if p.Emoji != oldName && i < 0 {
+			return nil
+		}
+		if p.Emoji == oldName {
+			p.Emoji = newName
+		}
+		if i >= 0 {
+			p.Emojis[i] = newName
+		}
+		changed = append(changed, &p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range changed {
+		if err := s.PutPosted(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package store
+
+import "time"
+
+const lastRoundupKey = "last_roundup"
+
+// LastRoundup returns when the latest roundup was posted, or ErrNotFound
+func (s *Store) LastRoundup() (time.Time, error) {
+	var at time.Time
+	if err := s.get(bucketMeta, lastRoundupKey, &at); err != nil {
+		return time.Time{}, err
+	}
+	return at, nil
+}
+
+// PutLastRoundup records when a roundup was posted
+func (s *Store) PutLastRoundup(at time.Time) error {
+	return s.put(bucketMeta, lastRoundupKey, at)
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+// snapshot keys are UTC timestamps so they sort chronologically
+const snapshotKeyLayout = "2006-01-02T15:04:05.000000000Z"
+
+// SnapshotEmoji is a single catalog entry at the time of a snapshot
+type SnapshotEmoji struct {
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	AliasOf string `json:"alias_of,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+}
+
+// Snapshot is the full workspace emoji catalog at a point in time
+type Snapshot struct {
+	TakenAt time.Time       `json:"taken_at"`
+	Emojis  []SnapshotEmoji `json:"emojis"`
+}
+
+// PutSnapshot stores a catalog snapshot under the time it was taken
+func (s *Store) PutSnapshot(snap *Snapshot) error {
+	return s.put(bucketSnapshots, snapshotKey(snap.TakenAt), snap)
+}
+
+// SnapshotTimes returns when every stored snapshot was taken, oldest first
+func (s *Store) SnapshotTimes() ([]time.Time, error) {
+	var times []time.Time
+	err := s.backend.ForEach(bucketSnapshots, func(key string, _ []byte) error {
+		at, err := time.Parse(snapshotKeyLayout, key)
+		if err != nil {
+			return err
+		}
+		times = append(times, at)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times, nil
+}
+
+// GetSnapshot returns the snapshot taken at exactly the given time, or ErrNotFound
+func (s *Store) GetSnapshot(at time.Time) (*Snapshot, error) {
+	var snap Snapshot
+	if err := s.get(bucketSnapshots, snapshotKey(at), &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// SnapshotAt returns the latest snapshot taken at or before the given time,
+// or ErrNotFound if there is none
+func (s *Store) SnapshotAt(at time.Time) (*Snapshot, error) {
+	times, err := s.SnapshotTimes()
+	if err != nil {
+		return nil, err
+	}
+
+	i := sort.Search(len(times), func(i int) bool { return times[i].After(at) })
+	if i == 0 {
+		return nil, ErrNotFound
+	}
+	return s.GetSnapshot(times[i-1])
+}
+
+// PruneSnapshots removes snapshots taken before the given time and returns how
+// many were removed
+func (s *Store) PruneSnapshots(before time.Time) (int, error) {
+	times, err := s.SnapshotTimes()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, at := range times {
+		if !at.Before(before) {
+			break
+		}
+		if err := s.backend.Delete(bucketSnapshots, snapshotKey(at)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func snapshotKey(at time.Time) string {
+	return at.UTC().Format(snapshotKeyLayout)
+}
+
+// CatalogDiff lists the emojis that changed between two snapshots
+type CatalogDiff struct {
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Added    []SnapshotEmoji `json:"added"`
+	Removed  []SnapshotEmoji `json:"removed"`
+	Renamed  []RenamedEmoji  `json:"renamed"`
+	Reimaged []ReimagedEmoji `json:"reimaged"`
+}
+
+// RenamedEmoji is an emoji that kept its image under a new name
+type RenamedEmoji struct {
+	From SnapshotEmoji `json:"from"`
+	To   SnapshotEmoji `json:"to"`
+}
+
+// ReimagedEmoji is an emoji whose name stayed the same but whose image changed
+type ReimagedEmoji struct {
+	Before SnapshotEmoji `json:"before"`
+	After  SnapshotEmoji `json:"after"`
+}
+
+// content identifies what an emoji shows, so renames can be told apart from
+// a removal and an unrelated addition
+func (e SnapshotEmoji) content() string {
+	switch {
+	case e.AliasOf != "":
+		return "alias:" + e.AliasOf
+	case e.Hash != "":
+		return "hash:" + e.Hash
+	default:
+		return "url:" + e.URL
+	}
+}
+
+// sameImage compares two entries by hash when both have one, falling back to the URL
+func sameImage(a, b SnapshotEmoji) bool {
+	if a.AliasOf != "" || b.AliasOf != "" {
+		return a.AliasOf == b.AliasOf
+	}
+	if a.Hash != "" && b.Hash != "" {
+		return a.Hash == b.Hash
+	}
+	return a.URL == b.URL
+}
+
+// DiffSnapshots reports what changed in the catalog between from and to. A
+// removed and an added emoji with the same content count as a rename.
+func DiffSnapshots(from, to *Snapshot) *CatalogDiff {
+	diff := &CatalogDiff{
+		From:     from.TakenAt,
+		To:       to.TakenAt,
+		Added:    []SnapshotEmoji{},
+		Removed:  []SnapshotEmoji{},
+		Renamed:  []RenamedEmoji{},
+		Reimaged: []ReimagedEmoji{},
+	}
+
+	before := make(map[string]SnapshotEmoji, len(from.Emojis))
+	for _, e := range from.Emojis {
+		before[e.Name] = e
+	}
+	after := make(map[string]SnapshotEmoji, len(to.Emojis))
+	for _, e := range to.Emojis {
+		after[e.Name] = e
+	}
+
+	// removed emojis by content, waiting to be matched to an addition
+	gone := make(map[string][]SnapshotEmoji)
+	for _, e := range from.Emojis {
+		if _, ok := after[e.Name]; !ok {
+			gone[e.content()] = append(gone[e.content()], e)
+		}
+	}
+
+	for _, e := range to.Emojis {
+		old, ok := before[e.Name]
+		if ok {
+			if !sameImage(old, e) {
+				diff.Reimaged = append(diff.Reimaged, ReimagedEmoji{Before: old, After: e})
+			}
+			continue
+		}
+
+		if candidates := gone[e.content()]; len(candidates) > 0 {
+			diff.Renamed = append(diff.Renamed, RenamedEmoji{From: candidates[0], To: e})
+			gone[e.content()] = candidates[1:]
+			continue
+		}
+		diff.Added = append(diff.Added, e)
+	}
+
+	for _, candidates := range gone {
+		diff.Removed = append(diff.Removed, candidates...)
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Name < diff.Added[j].Name })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Name < diff.Removed[j].Name })
+	sort.Slice(diff.Renamed, func(i, j int) bool { return diff.Renamed[i].To.Name < diff.Renamed[j].To.Name })
+	sort.Slice(diff.Reimaged, func(i, j int) bool { return diff.Reimaged[i].After.Name < diff.Reimaged[j].After.Name })
+	return diff
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDiffSnapshots checks how catalog changes are told apart, in particular
+// renames against a removal followed by an unrelated addition
+func TestDiffSnapshots(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to []SnapshotEmoji
+		added    []string
+		removed  []string
+		renamed  []string // from>to
+		reimaged []string
+	}{
+		{
+			name: "unchanged",
+			from: []SnapshotEmoji{{Name: "cat", URL: "u1"}},
+			to:   []SnapshotEmoji{{Name: "cat", URL: "u1"}},
+		},
+		{
+			name:    "added and removed",
+			from:    []SnapshotEmoji{{Name: "cat", URL: "u1"}},
+			to:      []SnapshotEmoji{{Name: "dog", URL: "u2"}},
+			added:   []string{"dog"},
+			removed: []string{"cat"},
+		},
+		{
+			name:    "rename keeps the url",
+			from:    []SnapshotEmoji{{Name: "cat", URL: "u1"}},
+			to:      []SnapshotEmoji{{Name: "kitty", URL: "u1"}},
+			renamed: []string{"cat>kitty"},
+		},
+		{
+			name:    "rename matched by hash despite a new url",
+			from:    []SnapshotEmoji{{Name: "cat", URL: "u1", Hash: "h1"}},
+			to:      []SnapshotEmoji{{Name: "kitty", URL: "u2", Hash: "h1"}},
+			renamed: []string{"cat>kitty"},
+		},
+		{
+			name:    "renamed alias",
+			from:    []SnapshotEmoji{{Name: "cat", URL: "u1"}, {Name: "c", AliasOf: "cat"}},
+			to:      []SnapshotEmoji{{Name: "cat", URL: "u1"}, {Name: "kit", AliasOf: "cat"}},
+			renamed: []string{"c>kit"},
+		},
+		{
+			name:    "alias of another emoji isn't a rename",
+			from:    []SnapshotEmoji{{Name: "c", AliasOf: "cat"}},
+			to:      []SnapshotEmoji{{Name: "d", AliasOf: "dog"}},
+			added:   []string{"d"},
+			removed: []string{"c"},
+		},
+		{
+			name:     "new image under the same name",
+			from:     []SnapshotEmoji{{Name: "cat", URL: "u1", Hash: "h1"}},
+			to:       []SnapshotEmoji{{Name: "cat", URL: "u2", Hash: "h2"}},
+			reimaged: []string{"cat"},
+		},
+		{
+			name: "same hash under a new url is the same image",
+			from: []SnapshotEmoji{{Name: "cat", URL: "u1", Hash: "h1"}},
+			to:   []SnapshotEmoji{{Name: "cat", URL: "u2", Hash: "h1"}},
+		},
+		{
+			name:    "one removal only matches one addition",
+			from:    []SnapshotEmoji{{Name: "cat", URL: "u1"}},
+			to:      []SnapshotEmoji{{Name: "kitty", URL: "u1"}, {Name: "neko", URL: "u1"}},
+			added:   []string{"neko"},
+			renamed: []string{"cat>kitty"},
+		},
+		{
+			name:    "duplicates are matched in order",
+			from:    []SnapshotEmoji{{Name: "a", URL: "u1"}, {Name: "b", URL: "u1"}},
+			to:      []SnapshotEmoji{{Name: "x", URL: "u1"}},
+			removed: []string{"b"},
+			renamed: []string{"a>x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffSnapshots(&Snapshot{Emojis: tt.from}, &Snapshot{Emojis: tt.to})
+
+			var added, removed, renamed, reimaged []string
+			for _, e := range diff.Added {
+				added = append(added, e.Name)
+			}
+			for _, e := range diff.Removed {
+				removed = append(removed, e.Name)
+			}
+			for _, r := range diff.Renamed {
+				renamed = append(renamed, r.From.Name+">"+r.To.Name)
+			}
+			for _, r := range diff.Reimaged {
+				reimaged = append(reimaged, r.After.Name)
+			}
+
+			for _, check := range []struct {
+				what      string
+				got, want []string
+			}{
+				{"added", added, tt.added},
+				{"removed", removed, tt.removed},
+				{"renamed", renamed, tt.renamed},
+				{"reimaged", reimaged, tt.reimaged},
+			} {
+				if !reflect.DeepEqual(check.got, check.want) {
+					t.Errorf("%s = %v, want %v", check.what, check.got, check.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	bucketMeta      = "meta"
+	bucketEmojis    = "emojis"
+	bucketProcessed = "processed_events"
+	bucketOutbox    = "outbox"
+	bucketHistory   = "history"
+	bucketSnapshots = "snapshots"
+	bucketHeld      = "held"
+	bucketPosted    = "posted"
+)
+
+const baselineKey = "baseline_at"
+
+// buckets lists every bucket holding notifier state, in export order
+var buckets = []string{
+	bucketMeta,
+	bucketEmojis,
+	bucketProcessed,
+	bucketOutbox,
+	bucketHistory,
+	bucketSnapshots,
+	bucketHeld,
+	bucketPosted,
+}
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrNameTaken is returned when renaming an emoji to the name of an active one
+var ErrNameTaken = errors.New("name already taken")
+
+// Backend is a bucketed key/value store that the notifier state is persisted into
+type Backend interface {
+	Get(bucket, key string) ([]byte, error)
+	Put(bucket, key string, value []byte) error
+	Delete(bucket, key string) error
+	ForEach(bucket string, fn func(key string, value []byte) error) error
+	Close() error
+}
+
+// Opener creates a Backend from a driver-specific data source name
+type Opener func(dsn string) (Backend, error)
+
+var (
+	driversMu       sync.RWMutex
+	drivers         = make(map[string]Opener)
+	readOnlyOpeners = make(map[string]Opener)
+)
+
+// Register makes a Backend available under the given driver name
+func Register(driver string, opener Opener) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if opener == nil {
+		panic("store: Register opener is nil")
+	}
+	if _, dup := drivers[driver]; dup {
+		panic("store: Register called twice for driver " + driver)
+	}
+	drivers[driver] = opener
+}
+
+// RegisterReadOnly sets how a registered driver opens its backend for
+// OpenReadOnly. Drivers without one are opened as usual.
+func RegisterReadOnly(driver string, opener Opener) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if opener == nil {
+		panic("store: RegisterReadOnly opener is nil")
+	}
+	readOnlyOpeners[driver] = opener
+}
+
+// Drivers returns the sorted names of the registered drivers
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MessageRef points at a Slack message posted about an emoji
+type MessageRef struct {
+	Channel   string `json:"channel"`
+	Timestamp string `json:"ts"`
+}
+
+// AnnouncementCopy is an emoji's announcement in a destination other than the
+// first, which keeps its announcement on the emoji itself
+type AnnouncementCopy struct {
+	MessageRef
+	Destination string    `json:"destination"`
+	Sentence    string    `json:"sentence,omitempty"`
+	AnnouncedAt time.Time `json:"announced_at,omitzero"`
+}
+
+// Emoji is the persisted state of a single custom emoji
+type Emoji struct {
+	Name        string    `json:"name"`
+	URL         string    `json:"url,omitempty"`
+	AliasOf     string    `json:"alias_of,omitempty"`
+	Aliases     []string  `json:"aliases,omitempty"`
+	Active      bool      `json:"active"`
+	AddedAt     time.Time `json:"added_at,omitzero"`
+	RemovedAt   time.Time `json:"removed_at,omitzero"`
+	AnnouncedAt time.Time `json:"announced_at,omitzero"`
+	// HeldAt is when the emoji was held back for a digest or pack, and is
+	// cleared once that goes out. One still set after a restart was never announced.
+	HeldAt time.Time `json:"held_at,omitzero"`
+
+	// Sentence and Announcement record the posted announcement so it can be
+	// updated later. Copies record it in every other destination.
+	Sentence     string             `json:"sentence,omitempty"`
+	Announcement *MessageRef        `json:"announcement,omitempty"`
+	Copies       []AnnouncementCopy `json:"copies,omitempty"`
+
+	// AddedBy is the Slack user ID of the uploader. Slack's emoji events don't
+	// carry it, so it is only known when looked up with an admin token or
+	// imported with the rest of the state.
+	AddedBy string `json:"added_by,omitempty"`
+}
+
+// Store persists known emojis, announcements, processed events, undelivered
+// notifications, emoji history and catalog snapshots
+type Store struct {
+	backend Backend
+	mu      sync.Mutex
+}
+
+// Open opens the backend registered under driver and migrates it to the latest schema
+func Open(driver, dsn string) (*Store, error) {
+	driversMu.RLock()
+	opener, ok := drivers[driver]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown state driver %q (available: %v)", driver, Drivers())
+	}
+
+	backend, err := opener(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s state store: %w", driver, err)
+	}
+
+	s, err := New(backend)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenReadOnly opens the backend registered under driver for reading, without
+// waiting for a running listener to release it. The state isn't migrated, so
+// anything written to it fails.
+func OpenReadOnly(driver, dsn string) (*Store, error) {
+	driversMu.RLock()
+	opener, ok := readOnlyOpeners[driver]
+	if !ok {
+		opener, ok = drivers[driver]
+	}
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown state driver %q (available: %v)", driver, Drivers())
+	}
+
+	backend, err := opener(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s state store: %w", driver, err)
+	}
+
+	s := &Store{backend: backend}
+	current, err := s.SchemaVersion()
+	if err == nil && current > latestSchemaVersion() {
+		err = fmt.Errorf("state schema version %d is newer than supported version %d", current, latestSchemaVersion())
+	}
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// New wraps an already opened backend and migrates it to the latest schema
+func New(backend Backend) (*Store, error) {
+	s := &Store{backend: backend}
+	if err := s.migrate(); err != nil {
+		return nil, fmt.Errorf("failed to migrate state store: %w", err)
+	}
+	return s, nil
+}
+
+// Close releases the underlying backend
+func (s *Store) Close() error {
+	return s.backend.Close()
+}
+
+// GetEmoji returns the stored state for an emoji, or ErrNotFound
+func (s *Store) GetEmoji(name string) (*Emoji, error) {
+	var e Emoji
+	if err := s.get(bucketEmojis, name, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// PutEmoji creates or replaces the stored state for an emoji
+func (s *Store) PutEmoji(e *Emoji) error {
+	return s.put(bucketEmojis, e.Name, e)
+}
+
+// ListEmojis returns every stored emoji, sorted by name
+func (s *Store) ListEmojis() ([]*Emoji, error) {
+	var emojis []*Emoji
+	err := s.backend.ForEach(bucketEmojis, func(_ string, value []byte) error {
+		var e Emoji
+		if err := json.Unmarshal(value, &e); err != nil {
+			return err
+		}
+		emojis = append(emojis, &e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(emojis, func(i, j int) bool { return emojis[i].Name < emojis[j].Name })
+	return emojis, nil
+}
+
+// BaselineAt returns when the known emojis were first seeded from the
+// workspace catalog, or the zero time if they never were
+func (s *Store) BaselineAt() (time.Time, error) {
+	var at time.Time
+	err := s.get(bucketMeta, baselineKey, &at)
+	if errors.Is(err, ErrNotFound) {
+		return time.Time{}, nil
+	}
+	return at, err
+}
+
+// SetBaselineAt records when the known emojis were seeded from the workspace catalog
+func (s *Store) SetBaselineAt(at time.Time) error {
+	return s.put(bucketMeta, baselineKey, at)
+}
+
+// MarkProcessed records a key as processed at the given time. It returns false
+// when the key had already been recorded within the window.
+func (s *Store) MarkProcessed(key string, at time.Time, window time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var seen time.Time
+	err := s.get(bucketProcessed, key, &seen)
+	if err == nil && at.Sub(seen) < window {
+		return false, nil
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+
+	if err := s.put(bucketProcessed, key, at); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PruneProcessed forgets processed events recorded before the given time and
+// returns how many were removed
+func (s *Store) PruneProcessed(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []string
+	err := s.backend.ForEach(bucketProcessed, func(key string, value []byte) error {
+		var at time.Time
+		if err := json.Unmarshal(value, &at); err != nil {
+			return err
+		}
+		if at.Before(before) {
+			expired = append(expired, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range expired {
+		if err := s.backend.Delete(bucketProcessed, key); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+func (s *Store) get(bucket, key string, v interface{}) error {
+	value, err := s.backend.Get(bucket, key)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(value, v)
+}
+
+func (s *Store) put(bucket, key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.backend.Put(bucket, key, value)
+}
+
+// RenameEmoji moves an emoji's state, undelivered, held and posted
+// announcements, history and alias links from oldName to newName and returns
+// the moved emoji. It refuses with ErrNameTaken if an active emoji already
+// has newName. A removed one there is replaced, keeping its history.
+func (s *Store) RenameEmoji(oldName, newName string) (*Emoji, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var emoji Emoji
+	if err := s.get(bucketEmojis, oldName, &emoji); err != nil {
+		return nil, err
+	}
+	if oldName == newName {
+		return &emoji, nil
+	}
+
+	var existing Emoji
+	err := s.get(bucketEmojis, newName, &existing)
+	if err == nil && existing.Active {
+		return nil, fmt.Errorf("can't rename %s to %s: %w", oldName, newName, ErrNameTaken)
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	emoji.Name = newName
+	if err := s.put(bucketEmojis, newName, &emoji); err != nil {
+		return nil, err
+	}
+	if err := s.backend.Delete(bucketEmojis, oldName); err != nil {
+		return nil, err
+	}
+
+	if err := s.renameAliasLinks(oldName, newName); err != nil {
+		return nil, err
+	}
+	if err := s.renameOutbox(oldName, newName); err != nil {
+		return nil, err
+	}
+	if err := s.renameHeld(oldName, newName); err != nil {
+		return nil, err
+	}
+	if err := s.renamePosted(oldName, newName); err != nil {
+		return nil, err
+	}
+	if err := s.renameHistory(oldName, newName); err != nil {
+		return nil, err
+	}
+	return &emoji, nil
+}
+
+// renameAliasLinks points aliases of oldName, and alias lists naming it, at newName
+func (s *Store) renameAliasLinks(oldName, newName string) error {
+	var changed []*Emoji
+	err := s.backend.ForEach(bucketEmojis, func(_ string, value []byte) error {
+		var e Emoji
+		if err := json.Unmarshal(value, &e); err != nil {
+			return err
+		}
+		dirty := false
+		if e.AliasOf == oldName {
+			e.AliasOf = newName
+			e.URL = "alias:" + newName
+			dirty = true
+		}
+		for i, alias := range e.Aliases {
+			if alias == oldName {
+				e.Aliases[i] = newName
+				dirty = true
+			}
+		}
+		if dirty {
+			changed = append(changed, &e)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range changed {
+		if err := s.put(bucketEmojis, e.Name, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package store
+
+const dailyThreadKey = "daily_thread"
+
+// DailyThread is the parent message a day's announcements are threaded under
+// in one channel
+type DailyThread struct {
+	// Destination is the channel as configured, empty for the default channel.
+	// Channel is the ID Slack posted the message to.
+	Destination string   `json:"destination,omitempty"`
+	Day         string   `json:"day"`
+	Channel     string   `json:"channel"`
+	Timestamp   string   `json:"ts"`
+	Names       []string `json:"names,omitempty"`
+}
+
+// dailyThreadKeyFor keys a channel's daily thread. The default channel keeps
+// the key it had before threads were kept per channel.
+func dailyThreadKeyFor(destination string) string {
+	if destination == "" {
+		return dailyThreadKey
+	}
+	return dailyThreadKey + ":" + destination
+}
+
+// DailyThread returns the most recent daily thread in a configured channel,
+// empty for the default one, or ErrNotFound
+func (s *Store) DailyThread(destination string) (*DailyThread, error) {
+	var t DailyThread
+	if err := s.get(bucketMeta, dailyThreadKeyFor(destination), &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// PutDailyThread records the current daily thread of its channel
+func (s *Store) PutDailyThread(t *DailyThread) error {
+	return s.put(bucketMeta, dailyThreadKeyFor(t.Destination), t)
+}